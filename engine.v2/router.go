@@ -30,6 +30,30 @@ func cleanPath(p string) string {
 	return np
 }
 
+// joinPaths joins relativePath to the base path of a group like path.Join,
+// but keeps a trailing slash of relativePath, or of basePath if relativePath
+// is empty. Within a group it panics if relativePath has a '..' segment,
+// which would escape the group.
+func joinPaths(basePath, relativePath string) string {
+	if basePath != "" {
+		for _, segment := range strings.Split(relativePath, "/") {
+			if segment == ".." {
+				log.Panicf("invalid path: '..' segment in %s of group %s", relativePath, basePath)
+			}
+		}
+	}
+
+	if relativePath == "" {
+		return basePath
+	}
+
+	joined := path.Join(basePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
 var _HTTPMethods = []string{
 	http.MethodGet,
 	http.MethodPost,
@@ -63,9 +87,20 @@ type Router struct {
 	es     []*muxEntry
 	routes map[string]map[string]*muxEntry
 
+	// root is the router the routes of a group are registered on,
+	// it is nil for the root router itself.
+	root     *Router
 	basePath string
 }
 
+// rootRouter returns the router holding the registered routes.
+func (router *Router) rootRouter() *Router {
+	if router.root != nil {
+		return router.root
+	}
+	return router
+}
+
 func (router *Router) registered(httpMethod, pattern string) bool {
 	if _, exist := router.routes[httpMethod][pattern]; exist {
 		return true
@@ -86,15 +121,16 @@ func (router *Router) registered(httpMethod, pattern string) bool {
 
 // Handle registers a new request handle with the given pattern, method and handlers.
 func (router *Router) Handle(httpMethod, relativePath string, handlers ...Handler) {
-	router.mu.Lock()
-	defer router.mu.Unlock()
+	root := router.rootRouter()
+	root.mu.Lock()
+	defer root.mu.Unlock()
 
 	httpMethod = strings.ToUpper(httpMethod)
 	if !_HTTPMethodMap[httpMethod] {
 		log.Panicf("unknown HTTP method: %s %s", httpMethod, relativePath)
 	}
 
-	relativePath = path.Join(router.basePath, relativePath)
+	relativePath = joinPaths(router.basePath, relativePath)
 
 	pattern := cleanPath(relativePath)
 	// if pattern == "" {
@@ -105,7 +141,7 @@ func (router *Router) Handle(httpMethod, relativePath string, handlers ...Handle
 		log.Panicf("nil handler: %s %s", httpMethod, relativePath)
 	}
 
-	if exist := router.registered(httpMethod, pattern); exist {
+	if exist := root.registered(httpMethod, pattern); exist {
 		log.Panicf("multiple registrations: %s %s", httpMethod, relativePath)
 	}
 
@@ -115,9 +151,9 @@ func (router *Router) Handle(httpMethod, relativePath string, handlers ...Handle
 		handlers: handlers,
 	}
 
-	router.routes[httpMethod][pattern] = entry
+	root.routes[httpMethod][pattern] = entry
 
-	router.es = append(router.es, entry)
+	root.es = append(root.es, entry)
 }
 
 // Any registers a route that matches all the HTTP methods.
@@ -165,11 +201,19 @@ func (router *Router) OPTIONS(relativePath string, handlers ...Handler) {
 
 // Group creates a new router group.
 // You should add all the routes that have common middlewares or the same path prefix.
+// The relativePath is joined to the path of the parent group, groups can be nested:
+//     router.Group("v1", func(v1 *Router) {
+//         v1.Group("projects/:id", func(project *Router) {
+//             project.GET("comments", comments) // GET /v1/projects/:id/comments
+//         })
+//     })
+// The routes of a group are registered on the root router.
+// Within a group a relativePath with a '..' segment panics, it would escape the group.
 func (router *Router) Group(relativePath string, group func(group *Router)) {
 
 	var r = &Router{
-		// TODO(m)
-		basePath: relativePath,
+		root:     router.rootRouter(),
+		basePath: joinPaths(router.basePath, relativePath),
 	}
 
 	group(r)
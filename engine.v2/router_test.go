@@ -99,6 +99,98 @@ func TestRouterGroup(t *testing.T) {
 			group.GET("comments", comments)
 		})
 
+		So(router.routes["GET"], ShouldContainKey, "/projects/:id/comments")
+
+		Convey("Nested version groups", func() {
+
+			var router = new(Router)
+
+			router.Group("v1", func(v1 *Router) {
+				v1.GET("", comments)
+
+				v1.Group("/projects/:id/", func(project *Router) {
+					project.GET("comments", comments)
+
+					project.Group("admin", func(admin *Router) {
+						admin.Group("users/:user_id", func(user *Router) {
+							user.DELETE("/", comments)
+						})
+					})
+				})
+			})
+
+			router.Group("/v2", func(v2 *Router) {
+				v2.Group("projects/:id", func(project *Router) {
+					project.POST("comments", comments)
+				})
+			})
+
+			So(router.routes["GET"], ShouldContainKey, "/v1")
+			So(router.routes["GET"], ShouldContainKey, "/v1/projects/:id/comments")
+			So(router.routes["DELETE"], ShouldContainKey, "/v1/projects/:id/admin/users/:user_id/")
+			So(router.routes["POST"], ShouldContainKey, "/v2/projects/:id/comments")
+			So(router.es, ShouldHaveLength, 4)
+
+			So(func() {
+				router.Group("v1", func(v1 *Router) {
+					v1.Group("projects/:id", func(project *Router) {
+						project.GET("/comments", comments)
+					})
+				})
+			}, ShouldPanic)
+		})
+
+		Convey("Trailing slash", func() {
+
+			var router = new(Router)
+
+			router.Group("v1", func(v1 *Router) {
+				v1.GET("projects/", comments)
+				v1.GET("projects", comments)
+
+				v1.Group("users/", func(users *Router) {
+					users.GET("", comments)
+				})
+			})
+
+			So(router.routes["GET"], ShouldContainKey, "/v1/projects/")
+			So(router.routes["GET"], ShouldContainKey, "/v1/projects")
+			So(router.routes["GET"], ShouldContainKey, "/v1/users/")
+		})
+
+		Convey("Parent segments", func() {
+
+			var router = new(Router)
+
+			So(func() {
+				router.Group("admin", func(admin *Router) {
+					admin.Group("../public", func(public *Router) {
+						public.GET("index", comments)
+					})
+				})
+			}, ShouldPanic)
+
+			So(func() {
+				router.Group("admin", func(admin *Router) {
+					admin.GET("../x", comments)
+				})
+			}, ShouldPanic)
+
+			So(func() {
+				router.Group("admin", func(admin *Router) {
+					admin.GET("files/..data", comments)
+				})
+			}, ShouldNotPanic)
+
+			// there is no group to escape at the root
+			So(func() {
+				router.GET("/a/../b", comments)
+			}, ShouldNotPanic)
+
+			So(router.routes["GET"], ShouldContainKey, "/admin/files/..data")
+			So(router.routes["GET"], ShouldContainKey, "/b")
+			So(router.es, ShouldHaveLength, 2)
+		})
 	})
 
 }
@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// BatchRequest is a single sub-request of a batch, see Engine.Batch.
+type BatchRequest struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResponse is the response to a single sub-request of a batch.
+// Body holds the response body as is if it is valid JSON, otherwise it is
+// encoded as a JSON string.
+type BatchResponse struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type batchKey struct{}
+
+// Batch returns a handle which dispatches a batch of sub-requests through the
+// engine and replies with their responses, in the same order:
+//  router.POST("/batch", router.Batch(20, 4))
+//
+// The body of the batch request must be a JSON array of BatchRequest, e.g.
+//  [{"method": "GET", "path": "/products/1"}, {"method": "POST", "path": "/products", "body": {"name": "fox"}}]
+// and the reply is a JSON array of BatchResponse.
+//
+// Each sub-request is served like a regular request, including host routing,
+// redirects and the NotFound and MethodNotAllowed handlers. It inherits the
+// headers of the batch request, e.g. Authorization, which can be overridden
+// per sub-request. Sub-requests are not allowed to start another batch.
+//
+// Batches with more than maxSize sub-requests are rejected with 413 (Request
+// Entity Too Large), at most concurrency sub-requests are served at the same
+// time. A panic in a sub-request, which is not handled by the PanicHandler, is
+// reported as 500 (Internal Server Error) for that sub-request.
+func (engine *Engine) Batch(maxSize, concurrency int) HandlerFunc {
+	if maxSize < 1 || concurrency < 1 {
+		panic("batch size and concurrency must be positive")
+	}
+
+	return func(w http.ResponseWriter, req *http.Request, _ Params) {
+		if req.Context().Value(batchKey{}) != nil {
+			http.Error(w, "nested batch requests are not allowed", http.StatusBadRequest)
+			return
+		}
+
+		requests, err := decodeBatch(req.Body, maxSize)
+		if err == errBatchTooLarge {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, "invalid batch request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var (
+			wg        sync.WaitGroup
+			sem       = make(chan struct{}, concurrency)
+			responses = make([]BatchResponse, len(requests))
+			ctx       = context.WithValue(req.Context(), batchKey{}, true)
+		)
+
+		for i := range requests {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				responses[i] = engine.batchDispatch(ctx, req, &requests[i])
+			}(i)
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(responses)
+	}
+}
+
+var errBatchTooLarge = errors.New("too many sub-requests")
+
+// decodeBatch decodes the JSON array of sub-requests from r. It stops with
+// errBatchTooLarge as soon as the array has more than maxSize elements, the
+// rest of a large batch is not read.
+func decodeBatch(r io.Reader, maxSize int) ([]BatchRequest, error) {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('[') {
+		return nil, errors.New("batch must be a JSON array")
+	}
+
+	var requests []BatchRequest
+	for dec.More() {
+		if len(requests) == maxSize {
+			return nil, errBatchTooLarge
+		}
+
+		var br BatchRequest
+		if err := dec.Decode(&br); err != nil {
+			return nil, err
+		}
+		requests = append(requests, br)
+	}
+
+	// closing bracket
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// batchDispatch serves a single sub-request of the batch request parent.
+func (engine *Engine) batchDispatch(ctx context.Context, parent *http.Request, br *BatchRequest) (res BatchResponse) {
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			res = BatchResponse{Status: http.StatusInternalServerError}
+		}
+	}()
+
+	if br.Method == "" {
+		br.Method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, br.Method, br.Path, bytes.NewReader(br.Body))
+	if err != nil || len(br.Path) < 1 || br.Path[0] != '/' {
+		return BatchResponse{Status: http.StatusBadRequest}
+	}
+
+	// The body headers of the batch request don't apply to the sub-request
+	req.Header = parent.Header.Clone()
+	for _, key := range []string{"Content-Length", "Transfer-Encoding", "Content-Encoding", "Expect"} {
+		req.Header.Del(key)
+	}
+	for key, value := range br.Header {
+		req.Header.Set(key, value)
+	}
+	req.Host = parent.Host
+	req.RemoteAddr = parent.RemoteAddr
+	req.TLS = parent.TLS
+
+	recorder := engine.Dispatch(req)
+
+	res = BatchResponse{
+		Status: recorder.Code,
+		Header: recorder.Header(),
+	}
+
+	if body := recorder.Body.Bytes(); len(body) > 0 {
+		if json.Valid(body) {
+			res.Body = body
+		} else {
+			res.Body, _ = json.Marshal(string(body))
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEngineBatch(t *testing.T) {
+	router := New()
+	router.GET("/products/:id", func(w http.ResponseWriter, r *http.Request, ps Params) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q,"token":%q}`, ps.ByName("id"), r.Header.Get("Authorization"))
+	})
+	router.POST("/products", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	router.POST("/length", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		fmt.Fprintf(w, `{"length":%d,"header":%q,"encoding":%q}`, r.ContentLength, r.Header.Get("Content-Length"), r.Header.Get("Content-Encoding"))
+	})
+	router.GET("/panic", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		panic("oops!")
+	})
+	router.POST("/batch", router.Batch(5, 2))
+
+	body := `[
+		{"method": "GET", "path": "/products/1"},
+		{"method": "POST", "path": "/products", "body": {"name": "fox"}},
+		{"path": "/products/2", "header": {"Authorization": "other"}},
+		{"method": "GET", "path": "/nope"},
+		{"method": "GET", "path": "/panic"}
+	]`
+	r, _ := http.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	r.Header.Set("Authorization", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch request failed: Code=%d, Body=%s", w.Code, w.Body)
+	}
+
+	var responses []BatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("invalid batch response: %v", err)
+	}
+
+	want := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusOK, `{"id":"1","token":"secret"}`},
+		{http.StatusCreated, `{"name":"fox"}`},
+		{http.StatusOK, `{"id":"2","token":"other"}`},
+		{http.StatusNotFound, `"404 page not found\n"`},
+		{http.StatusInternalServerError, ``},
+	}
+	if len(responses) != len(want) {
+		t.Fatalf("wrong number of responses: want %d, got %d", len(want), len(responses))
+	}
+	for i, res := range responses {
+		if res.Status != want[i].status || string(res.Body) != want[i].body {
+			t.Errorf("response %d: want %d %s, got %d %s", i, want[i].status, want[i].body, res.Status, res.Body)
+		}
+	}
+
+	// too many sub-requests
+	r, _ = http.NewRequest(http.MethodPost, "/batch", strings.NewReader(`[{},{},{},{},{},{}]`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized batch, got: Code=%d", w.Code)
+	}
+
+	// the rest of an oversized batch is not decoded
+	r, _ = http.NewRequest(http.MethodPost, "/batch", strings.NewReader(`[{},{},{},{},{},{}, not json`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized batch with invalid rest, got: Code=%d", w.Code)
+	}
+
+	// invalid body
+	for _, body := range []string{`{}`, `[{}`, `[{"path": 1}]`, ``} {
+		r, _ = http.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for invalid batch %q, got: Code=%d", body, w.Code)
+		}
+	}
+
+	// nested batch and invalid path
+	r, _ = http.NewRequest(http.MethodPost, "/batch", strings.NewReader(`[{"method":"POST","path":"/batch","body":[]},{"path":"nope"}]`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	responses = nil
+	json.Unmarshal(w.Body.Bytes(), &responses)
+	if len(responses) != 2 || responses[0].Status != http.StatusBadRequest || responses[1].Status != http.StatusBadRequest {
+		t.Errorf("expected nested batch and invalid path to fail, got: %s", w.Body)
+	}
+
+	// the body headers of the batch request are not inherited
+	r, _ = http.NewRequest(http.MethodPost, "/batch", strings.NewReader(`[{"method":"POST","path":"/length","body":"fox.go"}]`))
+	r.Header.Set("Content-Length", "49")
+	r.Header.Set("Content-Encoding", "identity")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	responses = nil
+	json.Unmarshal(w.Body.Bytes(), &responses)
+	if len(responses) != 1 || string(responses[0].Body) != `{"length":8,"header":"","encoding":""}` {
+		t.Errorf("unexpected body headers of the sub-request: %s", w.Body)
+	}
+
+	recv := catchPanic(func() {
+		router.Batch(0, 1)
+	})
+	if recv == nil {
+		t.Error("invalid batch size did not panic")
+	}
+}
+
+func TestEngineBatchConcurrency(t *testing.T) {
+	var running, max int32
+
+	router := New()
+	router.GET("/slow", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	})
+	router.POST("/batch", router.Batch(10, 3))
+
+	body := "[" + strings.TrimSuffix(strings.Repeat(`{"path":"/slow"},`, 10), ",") + "]"
+	r, _ := http.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch request failed: Code=%d", w.Code)
+	}
+	if max > 3 {
+		t.Errorf("concurrency limit exceeded: %d sub-requests ran at the same time", max)
+	}
+}
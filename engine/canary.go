@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"math/rand"
+	"net/http"
+)
+
+// CanaryCookie is the name of the cookie which keeps a client on the variant
+// chosen by Canary.
+var CanaryCookie = "fox_variant"
+
+// CanaryHeader is the name of the response header Canary sets to the variant
+// which served the request, "stable" or "canary".
+var CanaryHeader = "X-Variant"
+
+// Canary returns a middleware which serves percent percent of the requests
+// with alt instead of the handle it wraps, e.g. for the gradual rollout of a
+// new implementation:
+//  canary := engine.Canary(10, SearchV2)
+//  router.GET("/search", canary(Search))
+//
+// The variant is chosen at random on the first request of a client and kept
+// in the CanaryCookie, so the client consistently gets the same variant. With
+// a percent of 0 or 100 the cookie is ignored, which rolls all clients back
+// or forward. The variant which served is sent in the CanaryHeader.
+func Canary(percent int, alt HandlerFunc) func(HandlerFunc) HandlerFunc {
+	if percent < 0 || percent > 100 {
+		panic("canary percent must be between 0 and 100")
+	}
+	if alt == nil {
+		panic("canary handle must not be nil")
+	}
+
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			variant := "stable"
+			switch percent {
+			case 0:
+			case 100:
+				variant = "canary"
+			default:
+				if cookie, err := req.Cookie(CanaryCookie); err == nil && (cookie.Value == "stable" || cookie.Value == "canary") {
+					variant = cookie.Value
+					break
+				}
+				if rand.Intn(100) < percent {
+					variant = "canary"
+				}
+				http.SetCookie(w, &http.Cookie{Name: CanaryCookie, Value: variant, Path: "/", HttpOnly: true})
+			}
+
+			w.Header().Set(CanaryHeader, variant)
+			if variant == "canary" {
+				alt(w, req, ps)
+				return
+			}
+			handle(w, req, ps)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanary(t *testing.T) {
+	handle := func(variant string) HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request, _ Params) {
+			w.Write([]byte(variant))
+		}
+	}
+
+	router := New()
+	router.GET("/none", Canary(0, handle("canary"))(handle("stable")))
+	router.GET("/all", Canary(100, handle("canary"))(handle("stable")))
+	router.GET("/half", Canary(50, handle("canary"))(handle("stable")))
+
+	serve := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if variant := w.Header().Get(CanaryHeader); variant != w.Body.String() {
+			t.Errorf("%s: variant header %q, served by %q", path, variant, w.Body.String())
+		}
+		return w
+	}
+
+	// 0 and 100 percent ignore the cookie and don't set one
+	canary := &http.Cookie{Name: CanaryCookie, Value: "canary"}
+	stable := &http.Cookie{Name: CanaryCookie, Value: "stable"}
+	if w := serve("/none", canary); w.Body.String() != "stable" || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("0 percent: served by %q, Set-Cookie %q", w.Body.String(), w.Header().Get("Set-Cookie"))
+	}
+	if w := serve("/all", stable); w.Body.String() != "canary" || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("100 percent: served by %q, Set-Cookie %q", w.Body.String(), w.Header().Get("Set-Cookie"))
+	}
+
+	// the cookie keeps a client on its variant
+	for i := 0; i < 20; i++ {
+		if w := serve("/half", canary); w.Body.String() != "canary" {
+			t.Fatalf("canary cookie: served by %q", w.Body.String())
+		}
+		if w := serve("/half", stable); w.Body.String() != "stable" {
+			t.Fatalf("stable cookie: served by %q", w.Body.String())
+		}
+	}
+
+	// new clients are sampled and get the cookie of their variant
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		w := serve("/half", &http.Cookie{Name: CanaryCookie, Value: "unknown"})
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != CanaryCookie || cookies[0].Value != w.Body.String() {
+			t.Fatalf("unexpected cookies %v for variant %q", cookies, w.Body.String())
+		}
+		counts[w.Body.String()]++
+	}
+	if counts["canary"] < 400 || counts["stable"] < 400 {
+		t.Errorf("unexpected distribution: %v", counts)
+	}
+
+	for _, fn := range []func(){
+		func() { Canary(-1, handle("canary")) },
+		func() { Canary(101, handle("canary")) },
+		func() { Canary(50, nil) },
+	} {
+		if recv := catchPanic(fn); recv == nil {
+			t.Error("invalid canary did not panic")
+		}
+	}
+}
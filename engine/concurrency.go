@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"net/http"
+)
+
+// MaxConcurrent returns a middleware which limits the number of requests
+// served at the same time by the handles it wraps to n. Requests exceeding
+// the limit are not queued, they are answered with 503 (Service Unavailable)
+// and a Retry-After header right away:
+//  expensive := engine.MaxConcurrent(10)
+//  router.GET("/reports/:id", expensive(Report))
+//
+// All handles wrapped by the same middleware share the limit, a slot is
+// released when the handle returns or panics.
+func MaxConcurrent(n int) func(HandlerFunc) HandlerFunc {
+	if n < 1 {
+		panic("concurrency limit must be positive")
+	}
+
+	sem := make(chan struct{}, n)
+
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				handle(w, req, ps)
+			default:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMaxConcurrent(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	limit := MaxConcurrent(2)
+
+	router := New()
+	router.PanicHandler = func(w http.ResponseWriter, _ *http.Request, _ interface{}) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	router.GET("/slow", limit(func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		started <- struct{}{}
+		<-release
+	}))
+	router.GET("/panic", limit(func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		panic("oops!")
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// panics release their slot
+	for i := 0; i < 3; i++ {
+		if w := serve("/panic"); w.Code != http.StatusInternalServerError {
+			t.Fatalf("unexpected panic response: Code=%d", w.Code)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve("/slow")
+		}()
+		<-started
+	}
+
+	// the limit is shared by the wrapped handles
+	for _, path := range []string{"/slow", "/panic"} {
+		w := serve(path)
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+			t.Errorf("%s: want Code=503 with Retry-After, got Code=%d Header=%v", path, w.Code, w.Header())
+		}
+	}
+
+	close(release)
+	wg.Wait()
+
+	if w := serve("/panic"); w.Code != http.StatusInternalServerError {
+		t.Errorf("slots not released: Code=%d", w.Code)
+	}
+
+	recv := catchPanic(func() {
+		MaxConcurrent(0)
+	})
+	if recv == nil {
+		t.Error("invalid concurrency limit did not panic")
+	}
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecompressRequest returns a middleware which transparently decompresses
+// request bodies sent with "Content-Encoding: gzip" or "deflate", so that the
+// handle reads the plain body:
+//  router.POST("/events", engine.DecompressRequest(10<<20)(CreateEvents))
+//
+// Requests with a malformed compressed body are answered with 400 (Bad
+// Request). To guard against decompression bombs, reading more than maxSize
+// decompressed bytes fails like with http.MaxBytesReader. Bodies without or
+// with another Content-Encoding are passed on unchanged.
+func DecompressRequest(maxSize int64) func(HandlerFunc) HandlerFunc {
+	if maxSize < 1 {
+		panic("maximum body size must be positive")
+	}
+
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			var (
+				body io.ReadCloser
+				err  error
+			)
+
+			switch strings.ToLower(req.Header.Get("Content-Encoding")) {
+			case "gzip", "x-gzip":
+				body, err = gzip.NewReader(req.Body)
+			case "deflate":
+				body, err = zlib.NewReader(req.Body)
+			default:
+				handle(w, req, ps)
+				return
+			}
+
+			if err != nil {
+				http.Error(w, "invalid compressed request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer body.Close()
+
+			req.Body = http.MaxBytesReader(w, body, maxSize)
+			req.ContentLength = -1
+			req.Header.Del("Content-Encoding")
+			req.Header.Del("Content-Length")
+
+			handle(w, req, ps)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompressRequest(t *testing.T) {
+	var (
+		body     string
+		readErr  error
+		encoding string
+	)
+
+	router := New()
+	router.POST("/events", DecompressRequest(64)(func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		b, err := ioutil.ReadAll(r.Body)
+		body, readErr, encoding = string(b), err, r.Header.Get("Content-Encoding")
+	}))
+
+	compress := func(encoding, s string) []byte {
+		var buf bytes.Buffer
+		if encoding == "deflate" {
+			zw := zlib.NewWriter(&buf)
+			zw.Write([]byte(s))
+			zw.Close()
+		} else {
+			gw := gzip.NewWriter(&buf)
+			gw.Write([]byte(s))
+			gw.Close()
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		encoding string
+		body     []byte
+		code     int
+		want     string
+		err      bool
+	}{
+		{"gzip", compress("gzip", `{"name":"fox"}`), http.StatusOK, `{"name":"fox"}`, false},
+		{"GZIP", compress("gzip", `{"name":"fox"}`), http.StatusOK, `{"name":"fox"}`, false},
+		{"deflate", compress("deflate", `{"name":"fox"}`), http.StatusOK, `{"name":"fox"}`, false},
+		{"", []byte(`{"name":"fox"}`), http.StatusOK, `{"name":"fox"}`, false},
+		{"gzip", []byte("not gzip"), http.StatusBadRequest, "", false},
+		{"deflate", []byte("not zlib"), http.StatusBadRequest, "", false},
+		{"gzip", compress("gzip", strings.Repeat("x", 1000)), http.StatusOK, strings.Repeat("x", 64), true},
+	}
+	for _, test := range tests {
+		body, readErr, encoding = "", nil, ""
+
+		r, _ := http.NewRequest(http.MethodPost, "/events", bytes.NewReader(test.body))
+		if test.encoding != "" {
+			r.Header.Set("Content-Encoding", test.encoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != test.code || body != test.want || (readErr != nil) != test.err || encoding != "" {
+			t.Errorf("Content-Encoding %q: want Code=%d body=%.20q err=%v, got Code=%d body=%.20q err=%v Content-Encoding=%q",
+				test.encoding, test.code, test.want, test.err, w.Code, body, readErr, encoding)
+		}
+	}
+
+	recv := catchPanic(func() {
+		DecompressRequest(0)
+	})
+	if recv == nil {
+		t.Error("invalid maximum body size did not panic")
+	}
+}
@@ -1,11 +1,18 @@
 package engine
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"time"
 )
 
 // HandlerFunc is a function that can be registered to a route to handle HTTP
@@ -26,6 +33,10 @@ type Engine struct {
 	// registered when this option was enabled.
 	SaveMatchedRoutePath bool
 
+	// The wildcard syntax of the registered paths, ColonSyntax by default.
+	// It must be set before any route is registered.
+	PathSyntax PathSyntax
+
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
 	// For example if /foo/ is requested but a route only exists for /foo, the
@@ -44,6 +55,17 @@ type Engine struct {
 	// RedirectTrailingSlash is independent of this option.
 	RedirectFixedPath bool
 
+	// If enabled, duplicate slashes in the request path are merged before the
+	// route is looked up, without a redirect. For example /products//1 is
+	// handled by the route /products/:id as if /products/1 was requested.
+	// The merged path is also set as the path of the request URL.
+	MergeSlashes bool
+
+	// The maximum length of a request path, requests with a longer path are
+	// answered with 414 (URI Too Long) and an empty body before any route is
+	// looked up. New sets DefaultMaxPathLength, zero means no limit.
+	MaxPathLength int
+
 	// If enabled, the router checks if another method is allowed for the
 	// current route, if the current request can not be routed.
 	// If this is the case, the request is answered with 'Method Not Allowed'
@@ -56,14 +78,31 @@ type Engine struct {
 	// Custom OPTIONS handlers take priority over automatic replies.
 	HandleOPTIONS bool
 
+	// If enabled, HEAD requests without a matching HEAD route are handled by
+	// the GET route of the path. The response body written by the GET handle is
+	// discarded, but counted for the Content-Length header, unless the handle
+	// sets it explicitly. The response header is therefore sent after the
+	// handle returned, flushing has no effect.
+	// Custom HEAD handlers and fallbacks take priority over automatic replies.
+	HandleHEAD bool
+
+	// If enabled, automatic OPTIONS replies have a JSON body listing the
+	// allowed methods in addition to the "Allow" header, e.g.
+	//  {"allow":["GET","OPTIONS","POST"]}
+	// It has no effect if GlobalOPTIONS is set.
+	OPTIONSBody bool
+
 	// An optional http.Handler that is called on automatic OPTIONS requests.
 	// The handler is only called if HandleOPTIONS is true and no OPTIONS
 	// handler for the specific path was set.
-	// The "Allowed" header is set before calling the handler.
+	// The "Allowed" header is set before calling the handler, the allowed
+	// methods are also available through AllowedMethodsFromContext.
 	GlobalOPTIONS http.Handler
 
-	// Cached value of global (*) allowed methods
-	globalAllowed string
+	// Routers of the virtual hosts, see Host
+	hosts         map[string]*Router
+	paramHosts    []*hostRouter
+	wildcardHosts []*hostRouter
 
 	// Configurable http.Handler which is called when no matching route is
 	// found. If it is not set, http.NotFound is used.
@@ -73,7 +112,8 @@ type Engine struct {
 	// cannot be routed and HandleMethodNotAllowed is true.
 	// If it is not set, http.Error with http.StatusMethodNotAllowed is used.
 	// The "Allow" header with allowed request methods is set before the handler
-	// is called.
+	// is called, the allowed methods are also available through
+	// AllowedMethodsFromContext.
 	MethodNotAllowed http.Handler
 
 	// Function to handle panics recovered from http handlers.
@@ -83,9 +123,58 @@ type Engine struct {
 	// unrecovered panics.
 	PanicHandler func(http.ResponseWriter, *http.Request, interface{})
 
+	// Like PanicHandler, but also receives the formatted stack trace of the
+	// goroutine that panicked, as returned by runtime/debug.Stack.
+	// The stack trace is only captured when a panic was recovered.
+	// If set, it is called instead of PanicHandler.
+	PanicHandlerWithStack func(http.ResponseWriter, *http.Request, interface{}, []byte)
+
+	// Timeouts of the http.Server started by Run, RunTLS, RunTLSConfig,
+	// RunUnix, RunFd and RunListener, see http.Server for their meaning.
+	// New sets defaults which protect against slow clients, a zero value
+	// means no timeout.
+	// WriteTimeout also limits the duration of a handle, it should be disabled
+	// or raised for routes that stream long responses.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// If enabled, the Params of every request are allocated instead of being
+	// taken from and returned to a pool. Pooling saves an allocation per
+	// request with parameters, but the Params are reused after the handle
+	// returned, so disabling it helps to track down bugs of handles which keep
+	// or pass on their Params, e.g. to a goroutine, instead of copying them.
+	// It should only be disabled for debugging.
+	DisableParamsPool bool
+
+	// Middleware of Wrap and the handler wrapping handleHTTPRequest with it
+	middleware []func(http.Handler) http.Handler
+	wrapped    http.Handler
+
+	// Servers started by the Run methods and the hooks of OnStart and OnShutdown
+	mu            sync.Mutex
+	servers       []*http.Server
+	startHooks    []func() error
+	shutdownHooks []func()
+
+	maxParams  uint16
 	paramsPool sync.Pool
 }
 
+// DefaultMaxPathLength is the maximum length of a request path of an Engine
+// returned by New.
+const DefaultMaxPathLength = 8192
+
+// Default timeouts of the http.Server started by the Run methods of an Engine
+// returned by New.
+const (
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+)
+
 // New returns a new initialized Engine.
 // Path auto-correction, including trailing slashes, is enabled by default.
 func New() *Engine {
@@ -95,6 +184,11 @@ func New() *Engine {
 		RedirectFixedPath:      true,
 		HandleMethodNotAllowed: true,
 		HandleOPTIONS:          true,
+		MaxPathLength:          DefaultMaxPathLength,
+		ReadTimeout:            DefaultReadTimeout,
+		ReadHeaderTimeout:      DefaultReadHeaderTimeout,
+		WriteTimeout:           DefaultWriteTimeout,
+		IdleTimeout:            DefaultIdleTimeout,
 		Router:                 Router{},
 	}
 
@@ -104,40 +198,197 @@ func New() *Engine {
 }
 
 func (engine *Engine) getParams() *Params {
+	if engine.DisableParamsPool {
+		ps := make(Params, 0, engine.maxParams)
+		return &ps
+	}
+
 	ps, _ := engine.paramsPool.Get().(*Params)
 	*ps = (*ps)[0:0] // reset slice
 	return ps
 }
 
 func (engine *Engine) putParams(ps *Params) {
-	if ps != nil {
+	if ps != nil && !engine.DisableParamsPool {
 		engine.paramsPool.Put(ps)
 	}
 }
 
 // Run attaches the router to a http.Server and starts listening and serving HTTP requests.
-// It is a shortcut for http.ListenAndServe(addr, router)
+// It is like http.ListenAndServe(addr, router), but applies the timeouts of the engine.
 // Note: this method will block the calling goroutine indefinitely unless an error happens.
 func (engine *Engine) Run(addr string) (err error) {
 
-	err = http.ListenAndServe(addr, engine)
+	if err = engine.start(); err != nil {
+		return
+	}
+
+	err = engine.newServer(addr).ListenAndServe()
 	return
 }
 
 // RunTLS attaches the router to a http.Server and starts listening and serving HTTPS (secure) requests.
-// It is a shortcut for http.ListenAndServeTLS(addr, certFile, keyFile, router)
+// It is like http.ListenAndServeTLS(addr, certFile, keyFile, router), but requires
+// at least TLS 1.2 and restricts TLS 1.2 connections to modern AEAD cipher suites.
 // Note: this method will block the calling goroutine indefinitely unless an error happens.
 func (engine *Engine) RunTLS(addr, certFile, keyFile string) (err error) {
 
-	err = http.ListenAndServeTLS(addr, certFile, keyFile, engine)
+	if err = engine.start(); err != nil {
+		return
+	}
+
+	server := engine.newServer(addr)
+	server.TLSConfig = newTLSConfig()
+
+	err = server.ListenAndServeTLS(certFile, keyFile)
+	return
+}
+
+// RunTLSConfig attaches the router to a http.Server and starts listening and serving HTTPS (secure)
+// requests with the given TLS configuration, which must provide the server certificates through
+// Certificates or GetCertificate.
+// The config is used as is, if it is nil the defaults of RunTLS are used.
+// Note: this method will block the calling goroutine indefinitely unless an error happens.
+func (engine *Engine) RunTLSConfig(addr string, config *tls.Config) (err error) {
+
+	if err = engine.start(); err != nil {
+		return
+	}
+
+	if config == nil {
+		config = newTLSConfig()
+	}
+
+	server := engine.newServer(addr)
+	server.TLSConfig = config
+
+	err = server.ListenAndServeTLS("", "")
 	return
 }
 
+// newServer returns a http.Server for the engine with its timeouts and keeps
+// it for Shutdown.
+func (engine *Engine) newServer(addr string) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           engine,
+		ReadTimeout:       engine.ReadTimeout,
+		ReadHeaderTimeout: engine.ReadHeaderTimeout,
+		WriteTimeout:      engine.WriteTimeout,
+		IdleTimeout:       engine.IdleTimeout,
+	}
+
+	engine.mu.Lock()
+	engine.servers = append(engine.servers, server)
+	engine.mu.Unlock()
+
+	return server
+}
+
+// OnStart registers a function to call before a Run method starts listening,
+// e.g. to warm caches or check database migrations. The functions are called
+// in registration order, if one of them returns an error, the next ones are
+// not called and the Run method returns the error without listening.
+func (engine *Engine) OnStart(f func() error) {
+	engine.mu.Lock()
+	engine.startHooks = append(engine.startHooks, f)
+	engine.mu.Unlock()
+}
+
+// start calls the functions registered with OnStart.
+func (engine *Engine) start() error {
+	engine.mu.Lock()
+	hooks := engine.startHooks
+	engine.mu.Unlock()
+
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnShutdown registers a function to call on Shutdown, e.g. to flush metrics
+// or close database connections. The functions are called in registration
+// order, after the servers stopped serving requests.
+func (engine *Engine) OnShutdown(f func()) {
+	engine.mu.Lock()
+	engine.shutdownHooks = append(engine.shutdownHooks, f)
+	engine.mu.Unlock()
+}
+
+// Shutdown gracefully shuts down the servers started by the Run methods, see
+// http.Server.Shutdown, and then calls the functions registered with
+// OnShutdown. The Run methods return http.ErrServerClosed once their server
+// is shut down.
+//
+// The context limits the time to wait for active connections and the
+// functions. If it expires, Shutdown returns the context's error without
+// waiting for the remaining functions and without calling the next ones.
+// Otherwise it returns the first error of shutting down the servers.
+func (engine *Engine) Shutdown(ctx context.Context) (err error) {
+	engine.mu.Lock()
+	servers, hooks := engine.servers, engine.shutdownHooks
+	engine.servers = nil
+	engine.mu.Unlock()
+
+	for _, server := range servers {
+		if serr := server.Shutdown(ctx); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	for _, hook := range hooks {
+		done := make(chan struct{})
+		go func(hook func()) {
+			defer close(done)
+			hook()
+		}(hook)
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
+
+// newTLSConfig returns the default TLS configuration used by RunTLS.
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+		},
+		// Only applies to TLS 1.2, TLS 1.3 cipher suites are not configurable
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	}
+}
+
 // RunUnix attaches the router to a http.Server and starts listening and serving HTTP requests
 // through the specified unix socket (ie. a file).
+// A stale socket file left by a server which didn't shut down cleanly is
+// removed first, the file is removed again when the server stops.
 // Note: this method will block the calling goroutine indefinitely unless an error happens.
 func (engine *Engine) RunUnix(file string) (err error) {
 
+	if err = engine.start(); err != nil {
+		return
+	}
+
+	removeStaleSocket(file)
+
 	listener, err := net.Listen("unix", file)
 	if err != nil {
 		return
@@ -145,7 +396,7 @@ func (engine *Engine) RunUnix(file string) (err error) {
 	defer listener.Close()
 	defer os.Remove(file)
 
-	err = http.Serve(listener, engine)
+	err = engine.newServer("").Serve(listener)
 	return
 }
 
@@ -154,44 +405,149 @@ func (engine *Engine) RunUnix(file string) (err error) {
 // Note: this method will block the calling goroutine indefinitely unless an error happens.
 func (engine *Engine) RunFd(fd int) (err error) {
 
+	if err = engine.start(); err != nil {
+		return
+	}
+
 	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd@%d", fd))
 	listener, err := net.FileListener(f)
 	if err != nil {
 		return
 	}
 	defer listener.Close()
-	err = engine.RunListener(listener)
+	err = engine.newServer("").Serve(listener)
 	return
 }
 
+// removeStaleSocket removes the unix socket file if no server listens on it.
+// Other files and sockets in use are kept, listening on them fails.
+func removeStaleSocket(file string) {
+	if info, err := os.Lstat(file); err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+
+	if conn, err := net.Dial("unix", file); err == nil {
+		conn.Close()
+		return
+	}
+	os.Remove(file)
+}
+
 // RunListener attaches the router to a http.Server and starts listening and serving HTTP requests
 // through the specified net.Listener
 func (engine *Engine) RunListener(listener net.Listener) (err error) {
 
-	err = http.Serve(listener, engine)
+	if err = engine.start(); err != nil {
+		return
+	}
+
+	err = engine.newServer("").Serve(listener)
 	return
 }
 
 // ServeHTTP makes the router implement the http.Handler interface.
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if engine.wrapped != nil {
+		engine.wrapped.ServeHTTP(w, req)
+		return
+	}
 	engine.handleHTTPRequest(w, req)
 }
 
+// Wrap adds standard net/http middleware around the whole engine, outside of
+// the routing, e.g. to time or log every request, including those answered
+// with a redirect, 404 or 405:
+//  router.Wrap(timing, logging)
+//
+// Middleware is applied in registration order, the first one is the
+// outermost and sees the request first. Dispatch serves requests without the
+// middleware.
+func (engine *Engine) Wrap(middleware ...func(http.Handler) http.Handler) {
+	var handler http.Handler = http.HandlerFunc(engine.handleHTTPRequest)
+
+	engine.middleware = append(engine.middleware, middleware...)
+	for i := len(engine.middleware) - 1; i >= 0; i-- {
+		handler = engine.middleware[i](handler)
+	}
+	engine.wrapped = handler
+}
+
+// Dispatch serves a synthetic request with the engine and returns the recorded
+// response. It can be used for internal redirects and sub-requests and may be
+// called from within a handler, the params of the calling handler stay valid.
+//  res := router.Dispatch(httptest.NewRequest(http.MethodGet, "/products/1", nil))
+func (engine *Engine) Dispatch(req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	engine.handleHTTPRequest(recorder, req)
+	return recorder
+}
+
 func (engine *Engine) recv(w http.ResponseWriter, req *http.Request) {
 	if rcv := recover(); rcv != nil {
+		if engine.PanicHandlerWithStack != nil {
+			engine.PanicHandlerWithStack(w, req, rcv, debug.Stack())
+			return
+		}
 		engine.PanicHandler(w, req, rcv)
 	}
 }
 
 // handleHTTPRequest makes the router implement the http.Handler interface.
 func (engine *Engine) handleHTTPRequest(w http.ResponseWriter, req *http.Request) {
-	if engine.PanicHandler != nil {
+	if engine.PanicHandler != nil || engine.PanicHandlerWithStack != nil {
 		defer engine.recv(w, req)
 	}
 
 	path := req.URL.Path
 
-	if root := engine.Router.trees[req.Method]; root != nil {
+	if engine.MaxPathLength > 0 && len(path) > engine.MaxPathLength {
+		w.WriteHeader(http.StatusRequestURITooLong)
+		return
+	}
+
+	if engine.MergeSlashes {
+		if merged := mergeSlashes(path); len(merged) != len(path) {
+			path = merged
+			req.URL.Path = merged
+			req.URL.RawPath = ""
+		}
+	}
+
+	router := &engine.Router
+	if engine.hosts != nil || engine.paramHosts != nil || engine.wildcardHosts != nil {
+		router = engine.hostRouter(req.Host)
+	}
+
+	method := req.Method
+	root := router.trees[method]
+
+	// Serve HEAD requests with the GET routes, including the GET fallbacks
+	if method == http.MethodHead && engine.HandleHEAD &&
+		(router.trees[http.MethodGet] != nil || router.fallbacks[http.MethodGet] != nil) {
+		fallback := router.fallbacks[method]
+		if (root == nil || !root.match(path)) && (fallback == nil || !fallback.match(path)) {
+			// The header is only written if the handle returns, a panic is
+			// answered by the PanicHandler through the original writer
+			hw := &headResponseWriter{ResponseWriter: w}
+			engine.serve(hw, req, router, router.trees[http.MethodGet], http.MethodGet, path)
+			hw.finish()
+			return
+		}
+	}
+
+	engine.serve(w, req, router, root, method, path)
+}
+
+// serve serves the request with the routes of the given router, root is the
+// tree of method or nil.
+func (engine *Engine) serve(w http.ResponseWriter, req *http.Request, router *Router, root *node, method, path string) {
+	if root != nil {
+		// Fast path for static routes, which are matched by the exact path
+		if handle := router.statics[method][path]; handle != nil {
+			handle(w, req, nil)
+			return
+		}
+
 		if handle, ps, tsr := root.getValue(path, engine.getParams); handle != nil {
 			if ps != nil {
 				handle(w, req, *ps)
@@ -233,20 +589,40 @@ func (engine *Engine) handleHTTPRequest(w http.ResponseWriter, req *http.Request
 		}
 	}
 
+	// Try the low priority fallback routes
+	if root := router.fallbacks[method]; root != nil {
+		handle, ps, _ := root.getValue(path, engine.getParams)
+		if handle != nil {
+			if ps != nil {
+				handle(w, req, *ps)
+				engine.putParams(ps)
+			} else {
+				handle(w, req, nil)
+			}
+			return
+		}
+		engine.putParams(ps)
+	}
+
 	if req.Method == http.MethodOptions && engine.HandleOPTIONS {
 		// Handle OPTIONS requests
-		if allow := engine.Router.allowed(path, http.MethodOptions); allow != "" {
+		if allow := router.allowed(path, http.MethodOptions); allow != "" {
 			w.Header().Set("Allow", allow)
 			if engine.GlobalOPTIONS != nil {
-				engine.GlobalOPTIONS.ServeHTTP(w, req)
+				engine.GlobalOPTIONS.ServeHTTP(w, withAllowedMethods(req, allow))
+			} else if engine.OPTIONSBody {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				json.NewEncoder(w).Encode(struct {
+					Allow []string `json:"allow"`
+				}{strings.Split(allow, ", ")})
 			}
 			return
 		}
 	} else if engine.HandleMethodNotAllowed { // Handle 405
-		if allow := engine.Router.allowed(path, req.Method); allow != "" {
+		if allow := router.allowed(path, req.Method); allow != "" {
 			w.Header().Set("Allow", allow)
 			if engine.MethodNotAllowed != nil {
-				engine.MethodNotAllowed.ServeHTTP(w, req)
+				engine.MethodNotAllowed.ServeHTTP(w, withAllowedMethods(req, allow))
 			} else {
 				http.Error(w,
 					http.StatusText(http.StatusMethodNotAllowed),
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headResponseWriter answers a HEAD request with a GET handle. It discards
+// the body, but counts its size to send an accurate Content-Length header and
+// sniffs the Content-Type from the first chunk if the handle didn't set it.
+// The header is delayed until finish is called.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *headResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	// Sniff the Content-Type like net/http does for the GET response
+	if w.size == 0 && len(b) > 0 {
+		header := w.Header()
+		if _, haveType := header["Content-Type"]; !haveType && header.Get("Transfer-Encoding") == "" {
+			header.Set("Content-Type", http.DetectContentType(b))
+		}
+	}
+
+	w.size += len(b)
+	return len(b), nil
+}
+
+// Flush does nothing, the header is only written by finish. It lets GET
+// handles which flush their response serve HEAD requests as well.
+func (w *headResponseWriter) Flush() {}
+
+// finish writes the response header with the counted Content-Length.
+func (w *headResponseWriter) finish() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	header := w.Header()
+	if header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" && bodyAllowedForStatus(w.status) {
+		header.Set("Content-Length", strconv.Itoa(w.size))
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// bodyAllowedForStatus reports whether a given response status code
+// permits a body. See RFC 7230, section 3.3.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
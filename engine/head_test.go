@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEngineHandleHEAD(t *testing.T) {
+	product := map[string]string{
+		"name":        "fox",
+		"description": strings.Repeat("x", 10000), // larger than the server's chunking buffer
+	}
+
+	var getCalled bool
+
+	router := New()
+	router.GET("/products/:id", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		getCalled = true
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(product)
+	})
+	router.GET("/explicit", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Set("Content-Length", "42")
+		w.Write([]byte("short"))
+	})
+	router.GET("/empty", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.HEAD("/custom", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Set("X-Custom", "true")
+	})
+	router.GET("/custom", func(w http.ResponseWriter, _ *http.Request, _ Params) {})
+
+	// disabled by default
+	r, _ := http.NewRequest(http.MethodHead, "/products/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed || getCalled {
+		t.Fatalf("HEAD must not be served by GET by default: Code=%d", w.Code)
+	}
+
+	router.HandleHEAD = true
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get, err := http.Get(server.URL + "/products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get.Body.Close()
+
+	head, err := http.Head(server.URL + "/products/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.Body.Close()
+
+	body, _ := json.Marshal(product)
+	if head.StatusCode != http.StatusOK || head.ContentLength != int64(len(body)+1) {
+		t.Errorf("wrong HEAD response: Code=%d, Content-Length=%d, want %d", head.StatusCode, head.ContentLength, len(body)+1)
+	}
+	if ct := head.Header.Get("Content-Type"); ct != get.Header.Get("Content-Type") {
+		t.Errorf("HEAD and GET Content-Type differ: %s != %s", ct, get.Header.Get("Content-Type"))
+	}
+
+	testRoutes := []struct {
+		path          string
+		code          int
+		contentLength string
+		custom        string
+	}{
+		{"/explicit", http.StatusOK, "42", ""},
+		{"/empty", http.StatusNoContent, "", ""},
+		{"/custom", http.StatusOK, "", "true"},
+		{"/products/1/", http.StatusPermanentRedirect, "0", ""}, // TSR of the GET route
+		{"/nope", http.StatusNotFound, "19", ""},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodHead, tr.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tr.code || w.Header().Get("Content-Length") != tr.contentLength || w.Header().Get("X-Custom") != tr.custom {
+			t.Errorf("HEAD %s: want Code=%d Content-Length=%q X-Custom=%q, got Code=%d Header=%v",
+				tr.path, tr.code, tr.contentLength, tr.custom, w.Code, w.Header())
+		}
+		if w.Body.Len() > 0 {
+			t.Errorf("HEAD %s: body was not discarded: %q", tr.path, w.Body)
+		}
+	}
+}
+
+func TestEngineHandleHEADPanic(t *testing.T) {
+	router := New()
+	router.HandleHEAD = true
+	router.PanicHandler = func(w http.ResponseWriter, _ *http.Request, _ interface{}) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	router.GET("/panic", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("partial"))
+		panic("oops!")
+	})
+
+	r, _ := http.NewRequest(http.MethodHead, "/panic", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("HEAD panic not handled by the PanicHandler: Code=%d", w.Code)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		t.Errorf("unexpected Content-Length of the partial body: %s", cl)
+	}
+}
+
+func TestEngineHandleHEADSniffContentType(t *testing.T) {
+	router := New()
+	router.HandleHEAD = true
+	router.GET("/page", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("<html><body>fox</body></html>"))
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get, err := http.Get(server.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get.Body.Close()
+
+	head, err := http.Head(server.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.Body.Close()
+
+	if ct := get.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected GET Content-Type: %s", ct)
+	}
+	if ct := head.Header.Get("Content-Type"); ct != get.Header.Get("Content-Type") {
+		t.Errorf("HEAD and GET Content-Type differ: %s != %s", ct, get.Header.Get("Content-Type"))
+	}
+}
+
+func TestEngineHandleHEADFlush(t *testing.T) {
+	router := New()
+	router.HandleHEAD = true
+	router.GET("/export", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("data"))
+		w.(http.Flusher).Flush()
+		SetTrailer(w, "X-Checksum", "3a6eb079")
+	})
+
+	r, _ := http.NewRequest(http.MethodHead, "/export", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "4" {
+		t.Errorf("HEAD request failed: Code=%d, Header=%v, Body=%q", w.Code, w.Header(), w.Body)
+	}
+	if w.Flushed {
+		t.Error("HEAD response flushed before the handle returned")
+	}
+}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"mime"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// RequireHeaders returns a middleware which answers requests missing any of
+// the given headers with 400 (Bad Request) and a message listing the missing
+// headers, e.g. for internal services:
+//  router.Register([]engine.Route{
+//      {Path: "/internal", Middleware: []func(engine.HandlerFunc) engine.HandlerFunc{
+//          engine.RequireHeaders("X-Internal-Token"),
+//      }, Routes: internalRoutes},
+//  })
+//
+// Header names are matched case-insensitively, a header with an empty value
+// is missing.
+func RequireHeaders(names ...string) func(HandlerFunc) HandlerFunc {
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			var missing []string
+			for _, name := range names {
+				if req.Header.Get(name) == "" {
+					missing = append(missing, http.CanonicalHeaderKey(name))
+				}
+			}
+
+			if missing != nil {
+				http.Error(w, "missing required headers: "+strings.Join(missing, ", "), http.StatusBadRequest)
+				return
+			}
+			handle(w, req, ps)
+		}
+	}
+}
+
+// RequireHeaderValue returns a middleware which answers requests with 400
+// (Bad Request) unless the first value of the given header is exactly value.
+// The header name is matched case-insensitively.
+func RequireHeaderValue(name, value string) func(HandlerFunc) HandlerFunc {
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			if req.Header.Get(name) != value {
+				http.Error(w, "invalid value of header "+http.CanonicalHeaderKey(name), http.StatusBadRequest)
+				return
+			}
+			handle(w, req, ps)
+		}
+	}
+}
+
+// Consumes returns a middleware which answers requests with a body whose
+// Content-Type is not one of the given media types with 415 (Unsupported Media
+// Type), before the handle reads the body:
+//  router.POST("/products", engine.Consumes("application/json")(CreateProduct))
+//
+// Media types are compared case-insensitively and without parameters, e.g.
+// "application/json; charset=utf-8" is accepted for "application/json".
+// Requests without a body are always accepted.
+func Consumes(types ...string) func(HandlerFunc) HandlerFunc {
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			if req.ContentLength != 0 && !consumes(types, req.Header.Get("Content-Type")) {
+				http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+				return
+			}
+			handle(w, req, ps)
+		}
+	}
+}
+
+// consumes reports whether the media type of contentType is one of types.
+func consumes(types []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range types {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Deprecated returns a middleware which marks the responses of deprecated
+// routes with the "Deprecation: true" header and, if sunset is not zero, the
+// "Sunset" header of RFC 8594 with the date after which the route may stop
+// working. If successor is not empty, a "Link" header points clients to the
+// replacement of the route:
+//  sunset := time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)
+//  router.GET("/v1/products", engine.Deprecated(sunset, "/v2/products")(ListProducts))
+func Deprecated(sunset time.Time, successor string) func(HandlerFunc) HandlerFunc {
+	var sunsetDate string
+	if !sunset.IsZero() {
+		sunsetDate = sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			header := w.Header()
+			header.Set("Deprecation", "true")
+			if sunsetDate != "" {
+				header.Set("Sunset", sunsetDate)
+			}
+			if successor != "" {
+				header.Add("Link", "<"+successor+">; rel=\"successor-version\"")
+			}
+			handle(w, req, ps)
+		}
+	}
+}
+
+// SetTrailer sets the HTTP trailer key of the response to value. Trailers are
+// sent after the response body, so unlike headers they may be set after the
+// body was written or flushed, e.g. a checksum of a streamed response:
+//  func Export(w http.ResponseWriter, r *http.Request, _ engine.Params) {
+//      hash := sha256.New()
+//      io.Copy(io.MultiWriter(w, hash), export)
+//      engine.SetTrailer(w, "X-Checksum", hex.EncodeToString(hash.Sum(nil)))
+//  }
+//
+// The trailer must be set before the handle returns.
+func SetTrailer(w http.ResponseWriter, key, value string) {
+	w.Header().Set(http.TrailerPrefix+key, value)
+}
+
+// IfMatch reports whether the If-Match header of the request allows to modify
+// a resource with the given current entity tag, for optimistic concurrency
+// control of PUT and PATCH requests:
+//  if !engine.IfMatch(r, product.ETag()) {
+//      engine.PreconditionFailed(w)
+//      return
+//  }
+//
+// The entity tag is given as sent in the ETag header, including the quotes,
+// e.g. `"v42"`, an empty tag means the resource doesn't exist.
+// Requests without the header are allowed, "If-Match: *" matches any
+// existing resource. Entity tags are compared strongly, weak tags never match.
+func IfMatch(req *http.Request, currentETag string) bool {
+	values := req.Header.Values("If-Match")
+	if len(values) == 0 {
+		return true
+	}
+
+	header := strings.Join(values, ",")
+	for {
+		header = textproto.TrimString(header)
+		if header == "" {
+			return false
+		}
+		if header[0] == ',' {
+			header = header[1:]
+			continue
+		}
+		if header[0] == '*' {
+			return currentETag != ""
+		}
+
+		etag, rest := scanETag(header)
+		if etag == "" {
+			return false
+		}
+		if etag == currentETag && etag[0] == '"' {
+			return true
+		}
+		header = rest
+	}
+}
+
+// PreconditionFailed answers the request with 412 (Precondition Failed), e.g.
+// if IfMatch reports false.
+func PreconditionFailed(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+}
+
+// scanETag returns the entity tag at the beginning of s and the rest of s,
+// or "" if s doesn't begin with a valid entity tag.
+func scanETag(s string) (etag, rest string) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s)-start < 2 || s[start] != '"' {
+		return "", ""
+	}
+
+	for i := start + 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return s[:i+1], s[i+1:]
+		case c == 0x21 || c >= 0x23 && c <= 0x7E || c >= 0x80:
+			// etagc
+		default:
+			return "", ""
+		}
+	}
+	return "", ""
+}
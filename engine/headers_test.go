@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireHeaders(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.GET("/internal", RequireHeaders("X-Internal-Token", "x-request-id")(handlerFunc))
+	router.GET("/version", RequireHeaderValue("accept-version", "v2")(handlerFunc))
+
+	tests := []struct {
+		path   string
+		header map[string]string
+		code   int
+		body   string
+	}{
+		{"/internal", map[string]string{"x-internal-token": "t", "X-Request-Id": "1"}, http.StatusOK, ""},
+		{"/internal", map[string]string{"X-Internal-Token": "t"}, http.StatusBadRequest, "missing required headers: X-Request-Id\n"},
+		{"/internal", map[string]string{"X-Internal-Token": ""}, http.StatusBadRequest, "missing required headers: X-Internal-Token, X-Request-Id\n"},
+		{"/version", map[string]string{"Accept-Version": "v2"}, http.StatusOK, ""},
+		{"/version", map[string]string{"Accept-Version": "V2"}, http.StatusBadRequest, "invalid value of header Accept-Version\n"},
+		{"/version", nil, http.StatusBadRequest, "invalid value of header Accept-Version\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		for key, value := range test.header {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s %v: want %d %q, got %d %q", test.path, test.header, test.code, test.body, w.Code, w.Body)
+		}
+	}
+}
+
+func TestConsumes(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.POST("/products", Consumes("application/json", "application/xml")(handlerFunc))
+
+	tests := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"application/json", "{}", http.StatusOK},
+		{"Application/JSON; charset=utf-8", "{}", http.StatusOK},
+		{"application/xml", "<product/>", http.StatusOK},
+		{"text/plain", "fox", http.StatusUnsupportedMediaType},
+		{"", "{}", http.StatusUnsupportedMediaType},
+		{"application/json;;", "{}", http.StatusUnsupportedMediaType},
+		{"", "", http.StatusOK}, // no body
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodPost, "/products", strings.NewReader(test.body))
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("Content-Type %q: want Code=%d, got Code=%d", test.contentType, test.code, w.Code)
+		}
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	handlerFunc := func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Add("Link", "</v1/products?page=2>; rel=\"next\"")
+	}
+
+	sunset := time.Date(2025, time.June, 30, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	router := New()
+	router.GET("/v1/products", Deprecated(sunset, "/v2/products")(handlerFunc))
+	router.GET("/v1/users", Deprecated(time.Time{}, "")(handlerFunc))
+
+	r, _ := http.NewRequest(http.MethodGet, "/v1/products", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if got := w.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("unexpected Deprecation header value: %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Mon, 30 Jun 2025 10:00:00 GMT" {
+		t.Errorf("unexpected Sunset header value: %q", got)
+	}
+	want := []string{`</v2/products>; rel="successor-version"`, `</v1/products?page=2>; rel="next"`}
+	if got := w.Header()["Link"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected Link header values: want %q, got %q", want, got)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/v1/users", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Sunset") != "" || len(w.Header()["Link"]) != 1 {
+		t.Errorf("unexpected headers without sunset and successor: %v", w.Header())
+	}
+}
+
+func TestSetTrailer(t *testing.T) {
+	router := New()
+	router.GET("/export", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("data"))
+		w.(http.Flusher).Flush()
+		SetTrailer(w, "X-Checksum", "3a6eb079")
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "data" {
+		t.Errorf("unexpected body: %q", body)
+	}
+	if got := resp.Trailer.Get("X-Checksum"); got != "3a6eb079" {
+		t.Errorf("unexpected X-Checksum trailer: %q", got)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header  []string
+		current string
+		match   bool
+	}{
+		{nil, `"v1"`, true},
+		{nil, "", true},
+		{[]string{`"v1"`}, `"v1"`, true},
+		{[]string{`"v1"`}, `"v2"`, false},
+		{[]string{`"v0", "v1"`}, `"v1"`, true},
+		{[]string{`"v0"`, `"v1"`}, `"v1"`, true},
+		{[]string{`"a,b" , "v1"`}, `"v1"`, true},
+		{[]string{`W/"v1"`}, `"v1"`, false},
+		{[]string{`"v1"`}, `W/"v1"`, false},
+		{[]string{`*`}, `"v1"`, true},
+		{[]string{`*`}, "", false},
+		{[]string{`"v1"`}, "", false},
+		{[]string{`v1`}, `v1`, false},
+		{[]string{`"v1`}, `"v1`, false},
+		{[]string{``}, `"v1"`, false},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodPut, "/products/1", nil)
+		for _, value := range test.header {
+			r.Header.Add("If-Match", value)
+		}
+		if got := IfMatch(r, test.current); got != test.match {
+			t.Errorf("If-Match %q for %q: want %t, got %t", test.header, test.current, test.match, got)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	PreconditionFailed(w)
+	if w.Code != http.StatusPreconditionFailed || w.Body.String() != "Precondition Failed\n" {
+		t.Errorf("unexpected response: Code=%d, Body=%q", w.Code, w.Body)
+	}
+}
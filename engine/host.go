@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostRouter is the router of a host pattern with wildcards, either a
+// parametrized pattern like ":tenant.example.com" or a wildcard subdomain
+// pattern like "*.example.com".
+type hostRouter struct {
+	pattern string
+	suffix  string // e.g. ".example.com" for "*.example.com"
+	router  *Router
+}
+
+// Host returns the router for the given host pattern, creating it on first
+// use. Routes registered on it only match requests whose Host header matches
+// the pattern:
+//  api := router.Host("api.example.com")
+//  api.GET("/products", ListProducts)
+//
+// A pattern is one of:
+//  Pattern               Matches
+//  api.example.com       exactly api.example.com
+//  :tenant.example.com   any single label, e.g. acme.example.com with tenant="acme"
+//  *.example.com         any host ending in .example.com, but not example.com itself
+//
+// Named labels may appear at any position of the pattern, their values are
+// appended to the Params of the matched route after the path parameters:
+//  router.Host(":tenant.example.com").GET("/users/:id", func(w http.ResponseWriter, r *http.Request, ps engine.Params) {
+//      tenant := ps.ByName("tenant")
+//  })
+//
+// Requests are dispatched to the router of the exact host first, then to the
+// first matching parametrized pattern in registration order, then to the
+// wildcard pattern with the longest suffix and finally to the default router
+// of the engine, which also serves requests of hosts without a router.
+// Host names are matched case-insensitively and a port in the Host header is
+// ignored.
+//
+// Each host router has its own routes, but the options, the NotFound,
+// MethodNotAllowed and PanicHandler handlers are those of the engine.
+func (engine *Engine) Host(pattern string) *Router {
+	pattern = strings.ToLower(pattern)
+
+	if pattern == "" {
+		panic("host must not be empty")
+	}
+
+	// Wildcard subdomain pattern
+	if strings.HasPrefix(pattern, "*.") {
+		if strings.ContainsAny(pattern[2:], "*/:") {
+			panic("invalid host pattern '" + pattern + "'")
+		}
+
+		suffix := pattern[1:]
+		for _, h := range engine.wildcardHosts {
+			if h.suffix == suffix {
+				return h.router
+			}
+		}
+
+		h := &hostRouter{
+			pattern: pattern,
+			suffix:  suffix,
+			router:  &Router{engine: engine},
+		}
+
+		// Keep the longest suffixes first
+		i := len(engine.wildcardHosts)
+		engine.wildcardHosts = append(engine.wildcardHosts, h)
+		for ; i > 0 && len(engine.wildcardHosts[i-1].suffix) < len(suffix); i-- {
+			engine.wildcardHosts[i], engine.wildcardHosts[i-1] = engine.wildcardHosts[i-1], engine.wildcardHosts[i]
+		}
+		return h.router
+	}
+
+	if strings.ContainsAny(pattern, "*/") {
+		panic("invalid host pattern '" + pattern + "'")
+	}
+
+	// Parametrized pattern
+	if strings.IndexByte(pattern, ':') >= 0 {
+		for _, h := range engine.paramHosts {
+			if h.pattern == pattern {
+				return h.router
+			}
+		}
+
+		labels := strings.Split(pattern, ".")
+		for _, label := range labels {
+			if label == "" || label == ":" || strings.LastIndexByte(label, ':') > 0 {
+				panic("invalid host pattern '" + pattern + "'")
+			}
+		}
+
+		h := &hostRouter{
+			pattern: pattern,
+			router: &Router{
+				engine:     engine,
+				hostLabels: labels,
+			},
+		}
+		engine.paramHosts = append(engine.paramHosts, h)
+		return h.router
+	}
+
+	if engine.hosts == nil {
+		engine.hosts = make(map[string]*Router)
+	}
+
+	r := engine.hosts[pattern]
+	if r == nil {
+		r = &Router{engine: engine}
+		engine.hosts[pattern] = r
+	}
+	return r
+}
+
+// hostRouter returns the router responsible for the given Host header.
+func (engine *Engine) hostRouter(host string) *Router {
+	host = strings.ToLower(stripHostPort(host))
+
+	if r := engine.hosts[host]; r != nil {
+		return r
+	}
+
+	for _, h := range engine.paramHosts {
+		if matchHostLabels(h.router.hostLabels, host) {
+			return h.router
+		}
+	}
+
+	for _, h := range engine.wildcardHosts {
+		if len(host) > len(h.suffix) && strings.HasSuffix(host, h.suffix) {
+			return h.router
+		}
+	}
+
+	return &engine.Router
+}
+
+// saveHostParams wraps the handle of a parametrized host router so that the
+// values of the named host labels are appended to the route params.
+func (r *Router) saveHostParams(handle HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request, ps Params) {
+		host := strings.ToLower(stripHostPort(req.Host))
+		if ps == nil {
+			psp := r.engine.getParams()
+			handle(w, req, appendHostParams((*psp)[0:0], r.hostLabels, host))
+			r.engine.putParams(psp)
+		} else {
+			handle(w, req, appendHostParams(ps, r.hostLabels, host))
+		}
+	}
+}
+
+// countHostParams returns the number of named labels of a host pattern.
+func countHostParams(labels []string) (n uint16) {
+	for _, label := range labels {
+		if label[0] == ':' {
+			n++
+		}
+	}
+	return
+}
+
+// matchHostLabels reports whether host matches the labels of a parametrized
+// host pattern. Named labels match any non-empty label.
+func matchHostLabels(labels []string, host string) bool {
+	for i, label := range labels {
+		end := strings.IndexByte(host, '.')
+		if i == len(labels)-1 {
+			if end >= 0 {
+				return false
+			}
+			end = len(host)
+		} else if end < 0 {
+			return false
+		}
+
+		if label[0] == ':' {
+			if end == 0 {
+				return false
+			}
+		} else if host[:end] != label {
+			return false
+		}
+
+		if end < len(host) {
+			end++ // skip '.'
+		}
+		host = host[end:]
+	}
+	return true
+}
+
+// appendHostParams appends the values of the named labels of a matching host
+// to ps.
+func appendHostParams(ps Params, labels []string, host string) Params {
+	for _, label := range labels {
+		end := strings.IndexByte(host, '.')
+		if end < 0 {
+			end = len(host)
+		}
+
+		if label[0] == ':' {
+			ps = append(ps, Param{Key: label[1:], Value: host[:end]})
+		}
+
+		if end < len(host) {
+			end++ // skip '.'
+		}
+		host = host[end:]
+	}
+	return ps
+}
+
+// stripHostPort returns host without the optional port, e.g. "example.com"
+// for "example.com:8080" and "::1" for "[::1]:8080".
+func stripHostPort(host string) string {
+	if strings.IndexByte(host, ':') < 0 {
+		return host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEngineHost(t *testing.T) {
+	var handled string
+	handle := func(name string) HandlerFunc {
+		return func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+			handled = name
+		}
+	}
+
+	router := New()
+	router.GET("/", handle("default"))
+	router.Host("api.example.com").GET("/", handle("api"))
+	router.Host("API.example.com").GET("/products/:id", handle("api product"))
+	router.Host("*.example.com").GET("/", handle("wildcard"))
+	router.Host("*.eu.example.com").GET("/", handle("eu wildcard"))
+
+	if router.Host("api.example.com") != router.Host("Api.Example.Com") {
+		t.Error("host routers must be reused")
+	}
+
+	testHosts := []struct {
+		host    string
+		path    string
+		code    int
+		handled string
+	}{
+		{"api.example.com", "/", http.StatusOK, "api"},
+		{"api.example.com:8080", "/", http.StatusOK, "api"},
+		{"API.EXAMPLE.COM", "/", http.StatusOK, "api"},
+		{"api.example.com", "/products/1", http.StatusOK, "api product"},
+		{"www.example.com", "/", http.StatusOK, "wildcard"},
+		{"www.example.com", "/products/1", http.StatusNotFound, ""},
+		{"www.eu.example.com:443", "/", http.StatusOK, "eu wildcard"},
+		{"example.com", "/", http.StatusOK, "default"},
+		{"localhost", "/", http.StatusOK, "default"},
+		{"[::1]:8080", "/", http.StatusOK, "default"},
+		{"localhost", "/products/1", http.StatusNotFound, ""},
+	}
+	for _, th := range testHosts {
+		handled = ""
+		r, _ := http.NewRequest(http.MethodGet, th.path, nil)
+		r.Host = th.host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != th.code || handled != th.handled {
+			t.Errorf("host %s%s: want Code=%d handled by %q, got Code=%d handled by %q",
+				th.host, th.path, th.code, th.handled, w.Code, handled)
+		}
+	}
+
+	recv := catchPanic(func() {
+		router.Host("")
+	})
+	if recv == nil {
+		t.Error("empty host did not panic")
+	}
+
+	recv = catchPanic(func() {
+		router.Host("api.*.example.com")
+	})
+	if recv == nil {
+		t.Error("invalid host pattern did not panic")
+	}
+}
+
+func TestEngineHostParams(t *testing.T) {
+	routed := false
+
+	// the host router needs more params than the default router
+	router := New()
+	router.GET("/:a", func(_ http.ResponseWriter, _ *http.Request, _ Params) {})
+	router.Host("api.example.com").GET("/:a/:b/:c", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		routed = ps.ByName("c") == "3"
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/1/2/3", nil)
+	r.Host = "api.example.com"
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if !routed {
+		t.Fatal("routing failed")
+	}
+}
+
+func TestStripHostPort(t *testing.T) {
+	tests := map[string]string{
+		"example.com":      "example.com",
+		"example.com:8080": "example.com",
+		"127.0.0.1:80":     "127.0.0.1",
+		"[::1]:8080":       "::1",
+		"[::1]":            "[::1]",
+		"":                 "",
+	}
+	for host, want := range tests {
+		if got := stripHostPort(host); got != want {
+			t.Errorf("stripHostPort(%q): want %q, got %q", host, want, got)
+		}
+	}
+}
+
+func TestEngineHostParamPattern(t *testing.T) {
+	var got Params
+
+	router := New()
+	router.SaveMatchedRoutePath = true
+	router.Host("api.example.com").GET("/", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		got = Params{}
+	})
+	router.Host(":tenant.example.com").GET("/", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = ps
+	})
+	router.Host(":tenant.example.com").GET("/users/:id", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = ps
+	})
+	router.Host(":tenant.:region.example.com").GET("/", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = ps
+	})
+
+	testHosts := []struct {
+		host string
+		path string
+		ps   Params
+	}{
+		{"acme.example.com", "/", Params{{"tenant", "acme"}, {MatchedRoutePathParam, "/"}}},
+		{"Acme.Example.com:8080", "/", Params{{"tenant", "acme"}, {MatchedRoutePathParam, "/"}}},
+		{"acme.example.com", "/users/1", Params{{"id", "1"}, {"tenant", "acme"}, {MatchedRoutePathParam, "/users/:id"}}},
+		{"acme.eu.example.com", "/", Params{{"tenant", "acme"}, {"region", "eu"}, {MatchedRoutePathParam, "/"}}},
+		{"api.example.com", "/", Params{}}, // exact host wins
+		{"example.com", "/", nil},
+		{".example.com", "/", nil},
+		{"a.b.c.example.com", "/", nil},
+	}
+	for _, th := range testHosts {
+		got = nil
+		r, _ := http.NewRequest(http.MethodGet, th.path, nil)
+		r.Host = th.host
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(got, th.ps) {
+			t.Errorf("host %s%s: want params %v, got %v", th.host, th.path, th.ps, got)
+		}
+	}
+
+	for _, pattern := range []string{":.example.com", "a:b.example.com", "::a.example.com", "example.com:8080"} {
+		recv := catchPanic(func() {
+			router.Host(pattern)
+		})
+		if recv == nil {
+			t.Errorf("invalid host pattern '%s' did not panic", pattern)
+		}
+	}
+}
+
+func TestEngineHostGlobalOPTIONS(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.GET("/", handlerFunc)
+	api := router.Host("api.example.com")
+	api.POST("/products", handlerFunc)
+	api.DELETE("/products/:id", handlerFunc)
+
+	testHosts := map[string]string{
+		"api.example.com": "DELETE, OPTIONS, POST",
+		"www.example.com": "GET, OPTIONS",
+	}
+	for host, want := range testHosts {
+		r, _ := http.NewRequest(http.MethodOptions, "*", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if allow := w.Header().Get("Allow"); w.Code != http.StatusOK || allow != want {
+			t.Errorf("OPTIONS * of host %s: want Allow %q, got Code=%d Allow %q", host, want, w.Code, allow)
+		}
+	}
+
+	if got, want := api.AllowedMethods("*"), []string{"DELETE", "OPTIONS", "POST"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllowedMethods of the host router: want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import "net/http"
+
+// Tree is a standalone radix tree matcher using the same matching rules as
+// the Router, for building custom dispatchers. Instead of handles it stores
+// arbitrary values:
+//  tree := engine.NewTree()
+//  tree.Add(http.MethodGet, "/products/:id", "show product")
+//
+//  value, ps, _ := tree.Match(http.MethodGet, "/products/1")
+//  // value == "show product", ps.ByName("id") == "1"
+//
+// Like the Router, a Tree is safe for concurrent matching once all values
+// were added, but Add must not be called concurrently.
+type Tree struct {
+	trees     map[string]*node
+	maxParams uint16
+}
+
+// NewTree returns a new empty Tree.
+func NewTree() *Tree {
+	return &Tree{trees: make(map[string]*node)}
+}
+
+// valueLeaf is the handle marking the leaves of a Tree, it is never called.
+func valueLeaf(http.ResponseWriter, *http.Request, Params) {}
+
+// Add stores value for the given method and path. The path syntax and the
+// conflicts between paths are those of Router.Handle with the default
+// ColonSyntax, invalid or conflicting paths panic.
+func (t *Tree) Add(method, path string, value interface{}) {
+	if method == "" {
+		panic("method must not be empty")
+	}
+	if len(path) < 1 || path[0] != '/' {
+		panic("path must begin with '/' in path '" + path + "'")
+	}
+
+	root := t.trees[method]
+	if root == nil {
+		root = new(node)
+		t.trees[method] = root
+	}
+
+	root.addRoute(path, valueLeaf).value = value
+
+	if paramsCount := countParams(path); paramsCount > t.maxParams {
+		t.maxParams = paramsCount
+	}
+}
+
+// Match returns the value stored for the route matching method and path,
+// and the values of its wildcards.
+// If no route matches, the value is nil and tsr indicates whether a route
+// exists for the path with (without) a trailing slash.
+func (t *Tree) Match(method, path string) (value interface{}, ps Params, tsr bool) {
+	root := t.trees[method]
+	if root == nil {
+		return nil, nil, false
+	}
+
+	leaf, psp, tsr := root.getLeaf(path, t.newParams)
+	if leaf == nil {
+		return nil, nil, tsr
+	}
+	if psp != nil {
+		ps = *psp
+	}
+	return leaf.value, ps, false
+}
+
+// newParams allocates the Params of a match.
+func (t *Tree) newParams() *Params {
+	ps := make(Params, 0, t.maxParams)
+	return &ps
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	tree := NewTree()
+	tree.Add(http.MethodGet, "/products", "list")
+	tree.Add(http.MethodGet, "/products/:id", 42)
+	tree.Add(http.MethodPost, "/products", nil)
+	tree.Add(http.MethodGet, "/files/*filepath", []string{"files"})
+	tree.Add(http.MethodGet, "/pro", "pro") // splits the edge of /products
+
+	tests := []struct {
+		method string
+		path   string
+		value  interface{}
+		ps     Params
+		tsr    bool
+	}{
+		{http.MethodGet, "/products", "list", nil, false},
+		{http.MethodGet, "/pro", "pro", nil, false},
+		{http.MethodGet, "/products/1", 42, Params{{"id", "1"}}, false},
+		{http.MethodPost, "/products", nil, nil, false},
+		{http.MethodGet, "/files/a/b", []string{"files"}, Params{{"filepath", "/a/b"}}, false},
+		{http.MethodGet, "/files/", []string{"files"}, Params{{"filepath", "/"}}, false},
+		{http.MethodGet, "/products/1/", nil, nil, true},
+		{http.MethodGet, "/nope", nil, nil, false},
+		{http.MethodDelete, "/products", nil, nil, false},
+	}
+	for _, test := range tests {
+		value, ps, tsr := tree.Match(test.method, test.path)
+		if !reflect.DeepEqual(value, test.value) || !reflect.DeepEqual(ps, test.ps) || tsr != test.tsr {
+			t.Errorf("Match(%s, %s): want (%v, %v, %t), got (%v, %v, %t)",
+				test.method, test.path, test.value, test.ps, test.tsr, value, ps, tsr)
+		}
+	}
+
+	recv := catchPanic(func() {
+		tree.Add(http.MethodGet, "/products/:name", "conflict")
+	})
+	if recv == nil {
+		t.Error("conflicting path did not panic")
+	}
+}
+
+func TestTreeConcurrentMatch(t *testing.T) {
+	tree := NewTree()
+	tree.Add(http.MethodGet, "/a/:id", "a")
+	tree.Add(http.MethodGet, "/b/:id", "b")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if value, ps, _ := tree.Match(http.MethodGet, "/a/1"); value != "a" || ps.ByName("id") != "1" {
+					t.Errorf("wrong match: %v %v", value, ps)
+					return
+				}
+				if value, _, _ := tree.Match(http.MethodGet, "/b/2"); value != "b" {
+					t.Errorf("wrong match: %v", value)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -5,6 +5,8 @@
 
 package engine
 
+import "strings"
+
 // CleanPath is the URL version of path.Clean, it returns a canonical URL path
 // for p, eliminating . and .. elements.
 //
@@ -148,3 +150,23 @@ func bufApp(buf *[]byte, s string, w int, c byte) {
 	}
 	b[w] = c
 }
+
+// mergeSlashes replaces each run of multiple slashes in p with a single slash.
+// Unlike CleanPath it leaves . and .. elements untouched. If p contains no
+// duplicate slashes it is returned as is, without allocating.
+func mergeSlashes(p string) string {
+	i := strings.Index(p, "//")
+	if i < 0 {
+		return p
+	}
+
+	buf := make([]byte, i, len(p)-1)
+	copy(buf, p[:i])
+	for ; i < len(p); i++ {
+		if p[i] == '/' && len(buf) > 0 && buf[len(buf)-1] == '/' {
+			continue
+		}
+		buf = append(buf, p[i])
+	}
+	return string(buf)
+}
@@ -147,3 +147,27 @@ func BenchmarkPathCleanLong(b *testing.B) {
 		}
 	}
 }
+
+func TestPathMergeSlashes(t *testing.T) {
+	tests := []cleanPathTest{
+		{"", ""},
+		{"/", "/"},
+		{"//", "/"},
+		{"///", "/"},
+		{"/a//b", "/a/b"},
+		{"/a///b//c", "/a/b/c"},
+		{"/a//", "/a/"},
+		{"//a", "/a"},
+		{"/a/b/", "/a/b/"},
+		{"/a/./b//../c", "/a/./b/../c"},
+	}
+	for _, test := range tests {
+		if s := mergeSlashes(test.path); s != test.result {
+			t.Errorf("mergeSlashes(%q) = %q, want %q", test.path, s, test.result)
+		}
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { mergeSlashes("/a/b/c/") }); allocs > 0 {
+		t.Errorf("mergeSlashes: %v allocs for clean path, want zero", allocs)
+	}
+}
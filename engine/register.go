@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Route is an entry of a declarative route table, see Router.Register.
+type Route struct {
+	Method  string
+	Path    string
+	Handler HandlerFunc
+
+	// Middleware wrapping the Handler, the first one is the outermost.
+	// The middleware of a group also wraps the handlers of its routes.
+	Middleware []func(HandlerFunc) HandlerFunc
+
+	// Routes of a group, their paths are relative to Path. A group has no
+	// Method and Handler of its own.
+	Routes []Route
+}
+
+// Register registers the routes of a route table in order:
+//  errs := router.Register([]engine.Route{
+//      {Method: http.MethodGet, Path: "/health", Handler: Health},
+//      {Path: "/products", Middleware: []func(engine.HandlerFunc) engine.HandlerFunc{Auth}, Routes: []engine.Route{
+//          {Method: http.MethodGet, Path: "/", Handler: ListProducts},
+//          {Method: http.MethodGet, Path: "/:id", Handler: GetProduct},
+//      }},
+//  })
+//
+// Unlike Handle it does not panic on invalid routes, every route is validated
+// and registered on its own and an error is returned for each route which
+// could not be registered, so all problems of a table can be reported at once.
+// It returns nil if all routes were registered. Paths with a '..' segment are
+// rejected, they would escape the prefix and middleware of their group.
+func (r *Router) Register(routes []Route) (errs []error) {
+	r.register("", nil, routes, &errs)
+	return errs
+}
+
+func (r *Router) register(prefix string, middleware []func(HandlerFunc) HandlerFunc, routes []Route, errs *[]error) {
+	for _, route := range routes {
+		// path.Join would resolve a '..' segment and escape the group
+		if hasParentSegment(route.Path) {
+			*errs = append(*errs, fmt.Errorf("path must not contain '..' segments in path '%s'", prefix+route.Path))
+			continue
+		}
+
+		fullPath := prefix + route.Path
+		if prefix != "" && route.Path != "" {
+			// Keep a trailing slash of the route, path.Join would remove it
+			fullPath = path.Join(prefix, route.Path)
+			if strings.HasSuffix(route.Path, "/") && !strings.HasSuffix(fullPath, "/") {
+				fullPath += "/"
+			}
+		}
+
+		// The group middleware is the outermost
+		mw := append(append([]func(HandlerFunc) HandlerFunc{}, middleware...), route.Middleware...)
+
+		if route.Routes != nil {
+			if route.Method != "" || route.Handler != nil {
+				*errs = append(*errs, fmt.Errorf("group '%s' must not have a method or handler", fullPath))
+				continue
+			}
+			r.register(fullPath, mw, route.Routes, errs)
+			continue
+		}
+
+		if err := r.registerRoute(route.Method, fullPath, route.Handler, mw); err != nil {
+			*errs = append(*errs, err)
+		}
+	}
+}
+
+// registerRoute validates and registers a single route, a panic of Handle is
+// returned as an error.
+func (r *Router) registerRoute(method, path string, handle HandlerFunc, middleware []func(HandlerFunc) HandlerFunc) (err error) {
+	switch {
+	case !validMethod(method):
+		return fmt.Errorf("invalid method '%s' of route '%s'", method, path)
+	case len(path) < 1 || path[0] != '/':
+		return fmt.Errorf("path must begin with '/' in path '%s'", path)
+	case handle == nil:
+		return fmt.Errorf("handle must not be nil for %s '%s'", method, path)
+	}
+	for _, m := range middleware {
+		if m == nil {
+			return fmt.Errorf("middleware must not be nil for %s '%s'", method, path)
+		}
+	}
+
+	// A panic of a middleware is returned as well
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("%s '%s': %v", method, path, rcv)
+		}
+	}()
+
+	// Duplicates are reported with the name of the handler, not the chain
+	name := nameOfFunction(handle)
+	handle = Chain(middleware...)(handle)
+
+	r.addHandle(method, path, handle, name)
+	return nil
+}
+
+// Chain composes middleware into a single one, which applies them in order,
+// the first one is the outermost. It can be used to share a bundle of
+// middleware between routes which are not in the same group:
+//  protected := engine.Chain(Auth, RateLimit)
+//  router.GET("/products", protected(ListProducts))
+//  router.DELETE("/users/:id", protected(DeleteUser))
+//
+// A middleware which doesn't call the next handle stops the chain.
+func Chain(middleware ...func(HandlerFunc) HandlerFunc) func(HandlerFunc) HandlerFunc {
+	return func(handle HandlerFunc) HandlerFunc {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			handle = middleware[i](handle)
+		}
+		return handle
+	}
+}
+
+// hasParentSegment reports whether p has a '..' segment.
+func hasParentSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// validMethod reports whether method is a valid HTTP method token.
+func validMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for i := 0; i < len(method); i++ {
+		c := method[i]
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouterRegister(t *testing.T) {
+	var calls []string
+	handle := func(name string) HandlerFunc {
+		return func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+			calls = append(calls, name)
+		}
+	}
+	middleware := func(name string) func(HandlerFunc) HandlerFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request, ps Params) {
+				calls = append(calls, name)
+				next(w, r, ps)
+			}
+		}
+	}
+
+	router := New()
+	errs := router.Register([]Route{
+		{Method: http.MethodGet, Path: "/health", Handler: handle("health")},
+		{Path: "/api", Middleware: []func(HandlerFunc) HandlerFunc{middleware("api")}, Routes: []Route{
+			{Path: "/products", Routes: []Route{
+				{Method: http.MethodGet, Path: "/", Handler: handle("list")},
+				{Method: http.MethodGet, Path: "/:id", Handler: handle("get"), Middleware: []func(HandlerFunc) HandlerFunc{middleware("get")}},
+			}},
+		}},
+		{Method: "PROPFIND", Path: "/dav/*filepath", Handler: handle("dav")},
+	})
+	if errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		calls  []string
+	}{
+		{http.MethodGet, "/health", []string{"health"}},
+		{http.MethodGet, "/api/products/", []string{"api", "list"}},
+		{http.MethodGet, "/api/products/1", []string{"api", "get", "get"}},
+		{"PROPFIND", "/dav/docs", []string{"dav"}},
+	}
+	for _, test := range tests {
+		calls = nil
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !reflect.DeepEqual(calls, test.calls) {
+			t.Errorf("%s %s: want calls %v, got Code=%d calls %v", test.method, test.path, test.calls, w.Code, calls)
+		}
+	}
+}
+
+func TestRouterRegisterErrors(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+	middleware := func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, ps Params) {
+			next(w, r, ps)
+		}
+	}
+
+	router := New()
+	errs := router.Register([]Route{
+		{Method: "", Path: "/a", Handler: handlerFunc},
+		{Method: "GE T", Path: "/b", Handler: handlerFunc},
+		{Method: http.MethodGet, Path: "c", Handler: handlerFunc},
+		{Method: http.MethodGet, Path: "/d"},
+		{Method: http.MethodGet, Path: "/e", Handler: handlerFunc},
+		{Method: http.MethodGet, Path: "/e", Handler: handlerFunc},
+		{Method: http.MethodGet, Path: "/group", Routes: []Route{}},
+		{Method: http.MethodGet, Path: "/f", Handler: handlerFunc},
+		{Path: "/admin", Routes: []Route{
+			{Method: http.MethodGet, Path: "/../public", Handler: handlerFunc},
+			{Path: "/..", Routes: []Route{{Method: http.MethodGet, Path: "/g", Handler: handlerFunc}}},
+			{Method: http.MethodGet, Path: "/..h/", Handler: handlerFunc},
+		}},
+		{Path: "/mw", Middleware: []func(HandlerFunc) HandlerFunc{middleware}, Routes: []Route{
+			{Method: http.MethodGet, Path: "/x", Handler: handleProducts},
+		}},
+		{Method: http.MethodGet, Path: "/mw/x", Handler: handleProducts, Middleware: []func(HandlerFunc) HandlerFunc{middleware}},
+		{Path: "/nil", Middleware: []func(HandlerFunc) HandlerFunc{nil}, Routes: []Route{
+			{Method: http.MethodGet, Path: "/x", Handler: handlerFunc},
+		}},
+		{Method: http.MethodGet, Path: "/nil/y", Handler: handlerFunc, Middleware: []func(HandlerFunc) HandlerFunc{middleware, nil}},
+		{Method: http.MethodGet, Path: "/panic", Handler: handlerFunc, Middleware: []func(HandlerFunc) HandlerFunc{
+			func(HandlerFunc) HandlerFunc { panic("broken middleware") },
+		}},
+	})
+
+	want := []string{
+		"invalid method '' of route '/a'",
+		"invalid method 'GE T' of route '/b'",
+		"path must begin with '/' in path 'c'",
+		"handle must not be nil for GET '/d'",
+		"GET '/e': a handle is already registered for GET '/e': " +
+			"'github.com/miclle/fox/engine.TestRouterRegisterErrors.func1' conflicts with existing handle " +
+			"'github.com/miclle/fox/engine.TestRouterRegisterErrors.func1'",
+		"group '/group' must not have a method or handler",
+		"path must not contain '..' segments in path '/admin/../public'",
+		"path must not contain '..' segments in path '/admin/..'",
+		"GET '/mw/x': a handle is already registered for GET '/mw/x': " +
+			"'github.com/miclle/fox/engine.handleProducts' conflicts with existing handle " +
+			"'github.com/miclle/fox/engine.handleProducts'",
+		"middleware must not be nil for GET '/nil/x'",
+		"middleware must not be nil for GET '/nil/y'",
+		"GET '/panic': broken middleware",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("wrong number of errors: want %d, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d:\n want %q\n got  %q", i, want[i], err)
+		}
+	}
+
+	// valid routes are registered anyway
+	if routes := router.RouteList(); !reflect.DeepEqual(routes, []RouteEntry{{"GET", "/admin/..h/"}, {"GET", "/e"}, {"GET", "/f"}, {"GET", "/mw/x"}}) {
+		t.Errorf("wrong routes registered: %v", routes)
+	}
+}
+
+func TestRouterRegisterGlobalOPTIONS(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	errs := router.Register([]Route{
+		{Method: http.MethodGet, Path: "/c", Handler: handlerFunc},
+		{Method: http.MethodPut, Path: "/a/:", Handler: handlerFunc},
+		{Method: http.MethodPut, Path: "/b", Handler: handlerFunc},
+	})
+	if len(errs) != 1 {
+		t.Fatalf("want 1 error, got %v", errs)
+	}
+
+	r, _ := http.NewRequest(http.MethodPut, "/b", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("PUT /b: want Code=200, got Code=%d", w.Code)
+	}
+
+	r, _ = http.NewRequest(http.MethodOptions, "*", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, PUT" {
+		t.Errorf("OPTIONS *: want Allow %q, got %q", "GET, OPTIONS, PUT", allow)
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	middleware := func(name string, next bool) func(HandlerFunc) HandlerFunc {
+		return func(handle HandlerFunc) HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request, ps Params) {
+				calls = append(calls, name)
+				if next {
+					handle(w, r, ps)
+				}
+			}
+		}
+	}
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		calls = append(calls, "handle")
+	}
+
+	Chain(middleware("first", true), middleware("second", true))(handlerFunc)(nil, nil, nil)
+	if want := []string{"first", "second", "handle"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong call order: want %v, got %v", want, calls)
+	}
+
+	calls = nil
+	Chain(middleware("abort", false), middleware("second", true))(handlerFunc)(nil, nil, nil)
+	if want := []string{"abort"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("chain not stopped: want %v, got %v", want, calls)
+	}
+
+	calls = nil
+	Chain()(handlerFunc)(nil, nil, nil)
+	if want := []string{"handle"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("empty chain: want %v, got %v", want, calls)
+	}
+}
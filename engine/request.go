@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RemoteIP returns the IP of the directly connected peer from req.RemoteAddr
+// without the port, e.g. "::1" for "[::1]:8080". Unlike a client IP taken
+// from proxy headers like X-Forwarded-For it can't be spoofed by the client,
+// but it is the IP of the last proxy if the server runs behind one.
+// It returns "" if RemoteAddr doesn't hold an IP, e.g. for unix sockets.
+func RemoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		// no port
+		host = strings.TrimSuffix(strings.TrimPrefix(req.RemoteAddr, "["), "]")
+	}
+
+	// IPv6 addresses may have a zone, e.g. "fe80::1%eth0"
+	ip := host
+	if i := strings.IndexByte(ip, '%'); i >= 0 {
+		ip = ip[:i]
+	}
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+	return host
+}
+
+// ClientCertificate returns the verified leaf certificate of the client of a
+// mutual TLS connection, or nil for requests without TLS or without a verified
+// client certificate, e.g. to authenticate services:
+//  if cert := engine.ClientCertificate(r); cert != nil {
+//      cn, _ := engine.CertificateNames(cert)
+//  }
+//
+// The server must verify client certificates, e.g. with ClientAuth set to
+// tls.RequireAndVerifyClientCert in the configuration passed to RunTLSConfig.
+// Certificates which were not verified, e.g. with tls.RequireAnyClientCert,
+// are ignored.
+func ClientCertificate(req *http.Request) *x509.Certificate {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return req.TLS.VerifiedChains[0][0]
+}
+
+// CertificateNames returns the common name of the subject of cert and its
+// subject alternative names: the DNS names, email addresses, IP addresses
+// and URIs, in this order. It returns no names for a nil cert.
+func CertificateNames(cert *x509.Certificate) (commonName string, altNames []string) {
+	if cert == nil {
+		return "", nil
+	}
+
+	altNames = append(altNames, cert.DNSNames...)
+	altNames = append(altNames, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		altNames = append(altNames, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		altNames = append(altNames, uri.String())
+	}
+	return cert.Subject.CommonName, altNames
+}
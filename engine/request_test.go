@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestClientCertificate(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "orders"},
+		DNSNames:       []string{"orders.internal"},
+		EmailAddresses: []string{"ops@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/orders"}},
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if got := ClientCertificate(r); got != nil {
+		t.Errorf("non-TLS request: want nil, got %v", got)
+	}
+
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if got := ClientCertificate(r); got != nil {
+		t.Errorf("unverified certificate: want nil, got %v", got)
+	}
+
+	r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	if got := ClientCertificate(r); got != cert {
+		t.Errorf("verified certificate: want %v, got %v", cert, got)
+	}
+
+	cn, altNames := CertificateNames(cert)
+	want := []string{"orders.internal", "ops@example.com", "10.0.0.1", "spiffe://example.com/orders"}
+	if cn != "orders" || !reflect.DeepEqual(altNames, want) {
+		t.Errorf("CertificateNames: want %q %v, got %q %v", "orders", want, cn, altNames)
+	}
+
+	if cn, altNames := CertificateNames(nil); cn != "" || altNames != nil {
+		t.Errorf("CertificateNames(nil): want no names, got %q %v", cn, altNames)
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.1:1234":     "192.0.2.1",
+		"192.0.2.1":          "192.0.2.1",
+		"[::1]:8080":         "::1",
+		"[2001:db8::1]:443":  "2001:db8::1",
+		"[::1]":              "::1",
+		"::1":                "::1",
+		"[fe80::1%eth0]:80":  "fe80::1%eth0",
+		"@":                  "",
+		"":                   "",
+		"example.com:80":     "",
+		"[::1]:8080:garbage": "",
+	}
+	for remoteAddr, want := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if got := RemoteIP(r); got != want {
+			t.Errorf("RemoteIP(%q): want %q, got %q", remoteAddr, want, got)
+		}
+	}
+}
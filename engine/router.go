@@ -43,6 +43,9 @@
 //  :name     named parameter
 //  *name     catch-all parameter
 //
+// With Engine.PathSyntax set to BraceSyntax, {name} and {name...} are used
+// instead, e.g. /files/{filepath...}.
+//
 // Named parameters are dynamic path segments. They match anything until the
 // next '/' or the path end:
 //  Path: /blog/:category/:post
@@ -54,15 +57,17 @@
 //   /blog/go/request-routers/comments   no match
 //
 // Catch-all parameters match anything until the path end, including the
-// directory index (the '/' before the catch-all). Since they match anything
-// until the end, catch-all parameters must always be the final path element.
+// directory index (the '/' before the catch-all) and the empty remainder.
+// Since they match anything until the end, catch-all parameters must always be
+// the final path element. Requests matched by a catch-all are never
+// redirected to add or remove a trailing slash.
 //  Path: /files/*filepath
 //
 //  Requests:
 //   /files/                             match: filepath="/"
 //   /files/LICENSE                      match: filepath="/LICENSE"
 //   /files/templates/article.html       match: filepath="/templates/article.html"
-//   /files                              no match, but the router would redirect
+//   /files                              match: filepath=""
 //
 // The value of parameters is saved as a slice of the Param struct, consisting
 // each of a key and a value. The slice is passed to the Handle func as a third
@@ -79,6 +84,10 @@ package engine
 import (
 	"context"
 	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
 )
 
@@ -86,8 +95,23 @@ import (
 // handler functions via configurable routes
 type Router struct {
 	trees     map[string]*node
+	fallbacks map[string]*node
 	engine    *Engine
-	maxParams uint16
+
+	// Names of the registered handles by method and path, used to report
+	// duplicate registrations
+	handles         map[RouteEntry]string
+	fallbackHandles map[RouteEntry]string
+
+	// Handles of the routes without parameters by method and path, matched by
+	// the exact request path before the trees are traversed
+	statics map[string]map[string]HandlerFunc
+
+	// Cached value of global (*) allowed methods
+	globalAllowed string
+
+	// Labels of a parametrized host pattern, see Engine.Host
+	hostLabels []string
 }
 
 func (r *Router) saveMatchedRoutePath(path string, handle HandlerFunc) HandlerFunc {
@@ -148,7 +172,67 @@ func (r *Router) DELETE(path string, handle HandlerFunc) {
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
+//
+// Registering a handle for a method and path twice panics with the names of
+// both handles.
 func (r *Router) Handle(method, path string, handle HandlerFunc) {
+	r.addHandle(method, path, handle, "")
+}
+
+// addHandle registers the handle like Handle, name is the name of the user
+// handle wrapped by handle, it is reported for duplicate routes.
+// An empty name is taken from handle itself.
+func (r *Router) addHandle(method, path string, handle HandlerFunc, name string) {
+	if r.trees == nil {
+		r.trees = make(map[string]*node)
+		r.handles = make(map[RouteEntry]string)
+		r.statics = make(map[string]map[string]HandlerFunc)
+	}
+
+	// The server-wide allowed methods are those of each host
+	if r.addRoute(r.trees, r.handles, method, path, handle, name) {
+		r.globalAllowed = r.allowed("*", "")
+	}
+
+	// Keep the wrapped handle of static routes for the exact path fast path
+	if !strings.ContainsAny(path, ":*{") {
+		if r.statics[method] == nil {
+			r.statics[method] = make(map[string]HandlerFunc)
+		}
+		r.statics[method][path], _, _ = r.trees[method].getValue(path, nil)
+	}
+}
+
+// Fallback registers a low priority handle with the given path and method.
+//
+// Fallback routes live in their own trees, which are only consulted after
+// the regular routes of the request method, the trailing slash redirect and
+// the fixed path redirect all failed to handle the request. Regular routes
+// therefore always win, no matter how specific the fallback path is, and a
+// fallback may overlap regular routes which would otherwise conflict in the
+// same tree. For example a proxy registered as a fallback for "/*path" does
+// not shadow a regular "/health" route:
+//  router.GET("/health", health)
+//  router.Fallback(http.MethodGet, "/*path", proxy)
+//
+// Within the fallback trees the usual matching rules apply. Fallback routes
+// are not taken into account for the Allow header, a request which is only
+// matched by a fallback of another method is still answered with 405 if
+// HandleMethodNotAllowed is enabled. If no fallback matches either, the
+// request is delegated to the NotFound handler.
+func (r *Router) Fallback(method, path string, handle HandlerFunc) {
+	if r.fallbacks == nil {
+		r.fallbacks = make(map[string]*node)
+		r.fallbackHandles = make(map[RouteEntry]string)
+	}
+
+	r.addRoute(r.fallbacks, r.fallbackHandles, method, path, handle, "")
+}
+
+// addRoute adds the handle to the tree of the given method in trees, records
+// its name in handles and reports whether a new tree had to be created for the
+// method.
+func (r *Router) addRoute(trees map[string]*node, handles map[RouteEntry]string, method, path string, handle HandlerFunc, name string) (created bool) {
 	varsCount := uint16(0)
 
 	if method == "" {
@@ -161,44 +245,104 @@ func (r *Router) Handle(method, path string, handle HandlerFunc) {
 		panic("handle must not be nil")
 	}
 
+	if name == "" {
+		name = nameOfFunction(handle)
+	}
+
 	if r.engine.SaveMatchedRoutePath {
 		varsCount++
 		handle = r.saveMatchedRoutePath(path, handle)
 	}
 
-	if r.trees == nil {
-		r.trees = make(map[string]*node)
+	if n := countHostParams(r.hostLabels); n > 0 {
+		varsCount += n
+		handle = r.saveHostParams(handle)
 	}
 
-	root := r.trees[method]
+	// The matched route path is saved as registered, the tree uses the colon syntax
+	registered := path
+	if r.engine.PathSyntax == BraceSyntax {
+		path = translateBracePath(path)
+	}
+
+	// Report duplicates with both handles, before the tree panics
+	key := RouteEntry{Method: method, Path: registered}
+	if existing, ok := handles[key]; ok {
+		panic("a handle is already registered for " + method + " '" + registered +
+			"': '" + name + "' conflicts with existing handle '" + existing + "'")
+	}
+
+	// A new tree is only kept if the route could be added, a recovered panic
+	// must not hide the creation of the tree from later routes
+	root := trees[method]
 	if root == nil {
 		root = new(node)
-		r.trees[method] = root
-
-		r.engine.globalAllowed = r.allowed("*", "")
+		created = true
 	}
 
 	root.addRoute(path, handle)
+	if created {
+		trees[method] = root
+	}
+	handles[key] = name
 
-	// Update maxParams
-	if paramsCount := countParams(path); paramsCount+varsCount > r.maxParams {
-		r.maxParams = paramsCount + varsCount
+	// Update maxParams, shared by the routers of all hosts
+	engine := r.engine
+	if paramsCount := countParams(path); paramsCount+varsCount > engine.maxParams {
+		engine.maxParams = paramsCount + varsCount
 	}
 
 	// Lazy-init paramsPool alloc func
-	if r.engine.paramsPool.New == nil && r.maxParams > 0 {
-		r.engine.paramsPool.New = func() interface{} {
-			ps := make(Params, 0, r.maxParams)
+	if engine.paramsPool.New == nil && engine.maxParams > 0 {
+		engine.paramsPool.New = func() interface{} {
+			ps := make(Params, 0, engine.maxParams)
 			return &ps
 		}
 	}
+
+	return
+}
+
+// RouteEntry is the method and path of a registered route, see RouteList.
+type RouteEntry struct {
+	Method string
+	Path   string
+}
+
+// RouteList returns the method and path of all routes registered with Handle,
+// sorted by path and method. Paths are returned as registered.
+// Fallback routes are not included.
+//
+// It can be used in tests to check that every route is covered:
+//  for _, route := range router.RouteList() {
+//      if !tested[route] {
+//          t.Errorf("route %s %s is not tested", route.Method, route.Path)
+//      }
+//  }
+func (r *Router) RouteList() []RouteEntry {
+	routes := make([]RouteEntry, 0, len(r.handles))
+	for route := range r.handles {
+		routes = append(routes, route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
 }
 
 // Handler is an adapter which allows the usage of an http.Handler as a
 // request handle.
 // The Params are available in the request context under ParamsKey.
 func (r *Router) Handler(method, path string, handler http.Handler) {
-	r.Handle(method, path,
+	if handler == nil {
+		panic("handler must not be nil")
+	}
+
+	r.addHandle(method, path,
 		func(w http.ResponseWriter, req *http.Request, p Params) {
 			if len(p) > 0 {
 				ctx := req.Context()
@@ -207,6 +351,7 @@ func (r *Router) Handler(method, path string, handler http.Handler) {
 			}
 			handler.ServeHTTP(w, req)
 		},
+		nameOfHandler(handler),
 	)
 }
 
@@ -221,24 +366,78 @@ func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) {
 // path /defined/root/dir/*filepath.
 // For example if root is "/etc" and *filepath is "passwd", the local file
 // "/etc/passwd" would be served.
+// With BraceSyntax the path must end with "/{filepath...}" instead.
 // Internally a http.FileServer is used, therefore http.NotFound is used instead
 // of the Router's NotFound handler.
+// The root directory is always served with a trailing slash, a request for
+// the path without it is redirected.
 // To use the operating system's file system implementation,
 // use http.Dir:
 //     router.ServeFiles("/src/*filepath", http.Dir("/var/www"))
 func (r *Router) ServeFiles(path string, root http.FileSystem) {
-	if len(path) < 10 || path[len(path)-10:] != "/*filepath" {
-		panic("path must end with /*filepath in path '" + path + "'")
+	suffix := "/*filepath"
+	if r.engine.PathSyntax == BraceSyntax {
+		suffix = "/{filepath...}"
+	}
+	if !strings.HasSuffix(path, suffix) {
+		panic("path must end with " + suffix + " in path '" + path + "'")
 	}
 
 	fileServer := http.FileServer(root)
 
 	r.GET(path, func(w http.ResponseWriter, req *http.Request, ps Params) {
-		req.URL.Path = ps.ByName("filepath")
+		filepath := ps.ByName("filepath")
+		if filepath == "" {
+			// Relative links of the directory listing require the trailing slash
+			http.Redirect(w, req, req.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		req.URL.Path = filepath
 		fileServer.ServeHTTP(w, req)
 	})
 }
 
+// ServeFile serves the single file of the local file system with the given
+// name at path, for GET and HEAD requests:
+//     router.ServeFile("/favicon.ico", "./static/favicon.ico")
+// The file is opened on every request and served by http.ServeContent, which
+// sets the Content-Type from the file extension and the Last-Modified header
+// and handles conditional and range requests. Unless the header is already
+// set, the response is sent with "Cache-Control: no-cache", so clients cache
+// the file but revalidate it with If-Modified-Since and get 304 (Not
+// Modified) while it is unchanged.
+// The file doesn't need to exist yet, while it doesn't the request is
+// answered by http.NotFound. ServeFile panics if name is a directory.
+func (r *Router) ServeFile(path, name string) {
+	if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+		panic("cannot serve file at path '" + path + "': '" + name + "' is a directory")
+	}
+
+	handle := func(w http.ResponseWriter, req *http.Request, _ Params) {
+		f, err := os.Open(name)
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil || fi.IsDir() {
+			http.NotFound(w, req)
+			return
+		}
+
+		if w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		http.ServeContent(w, req, fi.Name(), fi.ModTime(), f)
+	}
+
+	r.GET(path, handle)
+	r.HEAD(path, handle)
+}
+
 // Lookup allows the manual lookup of a method + path combo.
 // This is e.g. useful to build a framework around this router.
 // If the path was found, it returns the handle function and the path parameter
@@ -273,7 +472,7 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 				allowed = append(allowed, method)
 			}
 		} else {
-			return r.engine.globalAllowed
+			return r.globalAllowed
 		}
 	} else { // specific path
 		for method := range r.trees {
@@ -309,3 +508,50 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 
 	return allow
 }
+
+// AllowedMethods returns the sorted methods allowed for the given path, as sent
+// in the "Allow" header of automatic OPTIONS and 405 replies, or nil if no route
+// matches the path. The path "*" returns the server-wide allowed methods.
+// It can be used by custom handlers and to generate documentation.
+func (r *Router) AllowedMethods(path string) []string {
+	allow := r.allowed(path, "")
+	if allow == "" {
+		return nil
+	}
+	return strings.Split(allow, ", ")
+}
+
+// nameOfFunction returns the name of the function f, e.g.
+// "main.ListProducts" or "main.main.func1" for a closure.
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+// nameOfHandler returns the function name of an http.HandlerFunc and the type
+// of other http.Handlers.
+func nameOfHandler(handler http.Handler) string {
+	if f, ok := handler.(http.HandlerFunc); ok {
+		return nameOfFunction(f)
+	}
+	return reflect.TypeOf(handler).String()
+}
+
+type allowedMethodsKey struct{}
+
+// withAllowedMethods returns a shallow copy of req carrying the comma separated
+// allowed methods in its context.
+func withAllowedMethods(req *http.Request, allow string) *http.Request {
+	ctx := context.WithValue(req.Context(), allowedMethodsKey{}, allow)
+	return req.WithContext(ctx)
+}
+
+// AllowedMethodsFromContext returns the sorted methods allowed for the
+// requested path from the request context of the MethodNotAllowed and
+// GlobalOPTIONS handlers, or nil for all other requests.
+func AllowedMethodsFromContext(ctx context.Context) []string {
+	allow, _ := ctx.Value(allowedMethodsKey{}).(string)
+	if allow == "" {
+		return nil
+	}
+	return strings.Split(allow, ", ")
+}
@@ -5,12 +5,20 @@
 package engine
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 type mockResponseWriter struct{}
@@ -196,6 +204,75 @@ func TestRouterInvalidInput(t *testing.T) {
 	if recv == nil {
 		t.Fatal("registering nil handler did not panic")
 	}
+
+	recv = catchPanic(func() {
+		router.Handler(http.MethodGet, "/", nil)
+	})
+	if recv == nil {
+		t.Fatal("registering nil http.Handler did not panic")
+	}
+}
+
+func handleProducts(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+func TestRouterDuplicateRoute(t *testing.T) {
+	router := New()
+	router.GET("/products/:id", handleProducts)
+	router.POST("/products/:id", handleProducts)
+	router.Fallback(http.MethodGet, "/products/:id", handleProducts)
+
+	recv := catchPanic(func() {
+		router.GET("/products/:id", func(_ http.ResponseWriter, _ *http.Request, _ Params) {})
+	})
+	msg, _ := recv.(string)
+	if !strings.HasPrefix(msg, "a handle is already registered for GET '/products/:id': 'github.com/miclle/fox/engine.TestRouterDuplicateRoute.") ||
+		!strings.HasSuffix(msg, "' conflicts with existing handle 'github.com/miclle/fox/engine.handleProducts'") {
+		t.Errorf("unexpected duplicate route panic: %v", recv)
+	}
+
+	recv = catchPanic(func() {
+		router.Fallback(http.MethodGet, "/products/:id", handleProducts)
+	})
+	if recv == nil {
+		t.Error("duplicate fallback route did not panic")
+	}
+
+	// http.Handlers are reported by their function or type name
+	router.Handler(http.MethodGet, "/docs/*filepath", http.FileServer(&mockFileSystem{}))
+	recv = catchPanic(func() {
+		router.Handler(http.MethodGet, "/docs/*filepath", http.NotFoundHandler())
+	})
+	if want := "a handle is already registered for GET '/docs/*filepath': 'net/http.NotFound' " +
+		"conflicts with existing handle '*http.fileHandler'"; recv != want {
+		t.Errorf("unexpected duplicate route panic:\n want %q\n got  %q", want, recv)
+	}
+}
+
+func TestRouterRouteList(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	if routes := router.RouteList(); len(routes) != 0 {
+		t.Errorf("unexpected routes of an empty router: %v", routes)
+	}
+
+	router.POST("/products", handlerFunc)
+	router.GET("/products/:id", handlerFunc)
+	router.GET("/products", handlerFunc)
+	router.DELETE("/products/:id", handlerFunc)
+	router.GET("/", handlerFunc)
+	router.Fallback(http.MethodGet, "/*path", handlerFunc)
+
+	want := []RouteEntry{
+		{http.MethodGet, "/"},
+		{http.MethodGet, "/products"},
+		{http.MethodPost, "/products"},
+		{http.MethodDelete, "/products/:id"},
+		{http.MethodGet, "/products/:id"},
+	}
+	if routes := router.RouteList(); !reflect.DeepEqual(routes, want) {
+		t.Errorf("wrong route list: want %v, got %v", want, routes)
+	}
 }
 
 func TestRouterChaining(t *testing.T) {
@@ -261,6 +338,128 @@ func BenchmarkAllowed(b *testing.B) {
 	})
 }
 
+// allocRoutes are the routes and requests of the allocation tests and
+// benchmarks of the route kinds.
+var allocRoutes = []struct {
+	name  string
+	route string
+	path  string
+}{
+	{"Static", "/health", "/health"},
+	{"Param", "/users/:name", "/users/gopher"},
+	{"CatchAll", "/files/*filepath", "/files/static/css/app.css"},
+}
+
+func newAllocRouter() *Engine {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	for _, route := range allocRoutes {
+		router.GET(route.route, handlerFunc)
+	}
+	return router
+}
+
+func TestRouterZeroAllocs(t *testing.T) {
+	router := newAllocRouter()
+	w := new(mockResponseWriter)
+
+	for _, route := range allocRoutes {
+		r, _ := http.NewRequest(http.MethodGet, route.path, nil)
+		allocs := testing.AllocsPerRun(100, func() { router.ServeHTTP(w, r) })
+		if allocs > 0 {
+			t.Errorf("%s route %s: %v allocs, want zero", route.name, route.path, allocs)
+		}
+	}
+}
+
+func BenchmarkRouterServe(b *testing.B) {
+	router := newAllocRouter()
+	w := new(mockResponseWriter)
+
+	for _, route := range allocRoutes {
+		r, _ := http.NewRequest(http.MethodGet, route.path, nil)
+		b.Run(route.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				router.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func BenchmarkRouterServeStatic(b *testing.B) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	for _, resource := range []string{"users", "orgs", "repos", "gists", "teams", "issues", "events", "notifications"} {
+		router.GET("/api/v1/"+resource, handlerFunc)
+		router.GET("/api/v1/"+resource+"/:id", handlerFunc)
+		router.GET("/api/v1/"+resource+"/:id/settings", handlerFunc)
+		router.GET("/api/v1/"+resource+"-search/recent", handlerFunc)
+	}
+	router.GET("/health", handlerFunc)
+
+	w := new(mockResponseWriter)
+	for _, path := range []string{"/health", "/api/v1/notifications-search/recent"} {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		b.Run(path, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				router.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func TestRouterStaticFastPath(t *testing.T) {
+	var got string
+	var gotPs Params
+	handle := func(name string) HandlerFunc {
+		return func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+			got, gotPs = name, ps
+		}
+	}
+
+	router := New()
+	router.SaveMatchedRoutePath = true
+	router.HandleHEAD = true
+	router.GET("/users", handle("users"))
+	router.GET("/users/:id", handle("user"))
+	router.GET("/files", handle("files"))
+	router.GET("/files/*filepath", handle("file"))
+	router.Host(":tenant.example.com").GET("/status", handle("status"))
+
+	tests := []struct {
+		method string
+		host   string
+		path   string
+		name   string
+		ps     Params
+	}{
+		{http.MethodGet, "", "/users", "users", Params{{MatchedRoutePathParam, "/users"}}},
+		{http.MethodGet, "", "/users/1", "user", Params{{"id", "1"}, {MatchedRoutePathParam, "/users/:id"}}},
+		{http.MethodGet, "", "/files", "files", Params{{MatchedRoutePathParam, "/files"}}},
+		{http.MethodGet, "", "/files/a.txt", "file", Params{{"filepath", "/a.txt"}, {MatchedRoutePathParam, "/files/*filepath"}}},
+		{http.MethodHead, "", "/users", "users", Params{{MatchedRoutePathParam, "/users"}}},
+		{http.MethodGet, "acme.example.com", "/status", "status", Params{{"tenant", "acme"}, {MatchedRoutePathParam, "/status"}}},
+		{http.MethodHead, "acme.example.com", "/status", "status", Params{{"tenant", "acme"}, {MatchedRoutePathParam, "/status"}}},
+	}
+	for _, test := range tests {
+		got, gotPs = "", nil
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		r.Host = test.host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || got != test.name || !reflect.DeepEqual(gotPs, test.ps) {
+			t.Errorf("%s %s%s: want %s %v, got Code=%d %s %v", test.method, test.host, test.path, test.name, test.ps, w.Code, got, gotPs)
+		}
+		if test.method == http.MethodHead && w.Body.Len() != 0 {
+			t.Errorf("%s %s%s: unexpected body %q", test.method, test.host, test.path, w.Body)
+		}
+	}
+}
+
 func TestRouterOPTIONS(t *testing.T) {
 	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
 
@@ -299,7 +498,9 @@ func TestRouterOPTIONS(t *testing.T) {
 	router.GET("/path", handlerFunc)
 
 	// set a global OPTIONS handler
+	var allowed []string
 	router.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed = AllowedMethodsFromContext(r.Context())
 		// Adjust status code to 204
 		w.WriteHeader(http.StatusNoContent)
 	})
@@ -324,6 +525,9 @@ func TestRouterOPTIONS(t *testing.T) {
 	} else if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
 		t.Error("unexpected Allow header value: " + allow)
 	}
+	if want := []string{"GET", "OPTIONS", "POST"}; !reflect.DeepEqual(allowed, want) {
+		t.Errorf("unexpected allowed methods in context: want %v, got %v", want, allowed)
+	}
 
 	// custom handler
 	var custom bool
@@ -357,6 +561,41 @@ func TestRouterOPTIONS(t *testing.T) {
 	}
 }
 
+func TestRouterOPTIONSBody(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.OPTIONSBody = true
+	router.GET("/path", handlerFunc)
+	router.POST("/path", handlerFunc)
+
+	r, _ := http.NewRequest(http.MethodOptions, "/path", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("OPTIONS handling failed: Code=%d, Header=%v", w.Code, w.Header())
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
+		t.Error("unexpected Allow header value: " + allow)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Error("unexpected Content-Type header value: " + ct)
+	}
+	if body := w.Body.String(); body != `{"allow":["GET","OPTIONS","POST"]}`+"\n" {
+		t.Errorf("unexpected OPTIONS body: %q", body)
+	}
+
+	// the global OPTIONS handler takes priority
+	router.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("GlobalOPTIONS handling failed: Code=%d, Body=%q", w.Code, w.Body)
+	}
+}
+
 func TestRouterNotAllowed(t *testing.T) {
 	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
 
@@ -390,11 +629,16 @@ func TestRouterNotAllowed(t *testing.T) {
 	// test custom handler
 	w = httptest.NewRecorder()
 	responseText := "custom method"
+	var allowed []string
 	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		allowed = AllowedMethodsFromContext(req.Context())
 		w.WriteHeader(http.StatusTeapot)
 		w.Write([]byte(responseText))
 	})
 	router.ServeHTTP(w, r)
+	if want := []string{"DELETE", "OPTIONS", "POST"}; !reflect.DeepEqual(allowed, want) {
+		t.Errorf("unexpected allowed methods in context: want %v, got %v", want, allowed)
+	}
 	if got := w.Body.String(); !(got == responseText) {
 		t.Errorf("unexpected response got %q want %q", got, responseText)
 	}
@@ -406,6 +650,46 @@ func TestRouterNotAllowed(t *testing.T) {
 	}
 }
 
+func TestRouterCustomMethods(t *testing.T) {
+	var handled string
+
+	router := New()
+	for _, method := range []string{"PROPFIND", "MKCOL", "REPORT"} {
+		method := method
+		router.Handle(method, "/dav/*filepath", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+			handled = method
+		})
+	}
+	router.GET("/dav/*filepath", func(_ http.ResponseWriter, _ *http.Request, _ Params) {})
+
+	r, _ := http.NewRequest("PROPFIND", "/dav/docs/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || handled != "PROPFIND" {
+		t.Errorf("custom method routing failed: Code=%d, handled=%q", w.Code, handled)
+	}
+
+	// not allowed
+	r, _ = http.NewRequest(http.MethodPut, "/dav/docs/", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("NotAllowed handling failed: Code=%d, Header=%v", w.Code, w.Header())
+	} else if allow := w.Header().Get("Allow"); allow != "GET, MKCOL, OPTIONS, PROPFIND, REPORT" {
+		t.Error("unexpected Allow header value: " + allow)
+	}
+
+	// automatic OPTIONS
+	r, _ = http.NewRequest(http.MethodOptions, "/dav/docs/", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("OPTIONS handling failed: Code=%d, Header=%v", w.Code, w.Header())
+	} else if allow := w.Header().Get("Allow"); allow != "GET, MKCOL, OPTIONS, PROPFIND, REPORT" {
+		t.Error("unexpected Allow header value: " + allow)
+	}
+}
+
 func TestRouterNotFound(t *testing.T) {
 	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
 
@@ -499,6 +783,42 @@ func TestRouterPanicHandler(t *testing.T) {
 	}
 }
 
+func TestRouterPanicHandlerWithStack(t *testing.T) {
+	router := New()
+	panicHandled := false
+	var stack []byte
+
+	router.PanicHandler = func(rw http.ResponseWriter, r *http.Request, p interface{}) {
+		t.Error("PanicHandler must not be called if PanicHandlerWithStack is set")
+	}
+	router.PanicHandlerWithStack = func(rw http.ResponseWriter, r *http.Request, p interface{}, s []byte) {
+		panicHandled = p == "oops!"
+		stack = s
+	}
+
+	router.Handle(http.MethodPut, "/user/:name", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		panic("oops!")
+	})
+
+	w := new(mockResponseWriter)
+	req, _ := http.NewRequest(http.MethodPut, "/user/gopher", nil)
+
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			t.Fatal("handling panic failed")
+		}
+	}()
+
+	router.ServeHTTP(w, req)
+
+	if !panicHandled {
+		t.Fatal("simulating failed")
+	}
+	if !strings.Contains(string(stack), "TestRouterPanicHandlerWithStack") {
+		t.Errorf("stack trace does not contain the panicking handler:\n%s", stack)
+	}
+}
+
 func TestRouterLookup(t *testing.T) {
 	routed := false
 	wantHandle := func(_ http.ResponseWriter, _ *http.Request, _ Params) {
@@ -697,3 +1017,651 @@ func TestRouterServeFiles(t *testing.T) {
 		t.Error("serving file failed")
 	}
 }
+
+func TestRouterServeFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "robots.txt")
+	if err := ioutil.WriteFile(name, []byte("User-agent: *\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	router := New()
+	router.ServeFile("/robots.txt", name)
+
+	r, _ := http.NewRequest(http.MethodGet, "/robots.txt", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "User-agent: *\n" {
+		t.Errorf("serving file failed: Code=%d, Body=%q", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Error("unexpected Content-Type header value: " + ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Error("unexpected Cache-Control header value: " + cc)
+	}
+	lastModified := w.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Error("Last-Modified header not set")
+	}
+
+	r, _ = http.NewRequest(http.MethodHead, "/robots.txt", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "14" {
+		t.Errorf("HEAD request failed: Code=%d, Header=%v, Body=%q", w.Code, w.Header(), w.Body)
+	}
+
+	// unchanged files are revalidated
+	r, _ = http.NewRequest(http.MethodGet, "/robots.txt", nil)
+	r.Header.Set("If-Modified-Since", lastModified)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("If-Modified-Since: want Code=304, got Code=%d, Body=%q", w.Code, w.Body)
+	}
+
+	// files removed after the registration are not found
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = http.NewRequest(http.MethodGet, "/robots.txt", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("removed file: want Code=404, got Code=%d", w.Code)
+	}
+
+	// files created after the registration are served
+	later := filepath.Join(dir, "later.txt")
+	router.ServeFile("/later.txt", later)
+	r, _ = http.NewRequest(http.MethodGet, "/later.txt", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing file: want Code=404, got Code=%d", w.Code)
+	}
+	if err := ioutil.WriteFile(later, []byte("later"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "later" {
+		t.Errorf("file created later: want Code=200, got Code=%d, Body=%q", w.Code, w.Body)
+	}
+
+	recv := catchPanic(func() {
+		router.ServeFile("/dir", dir)
+	})
+	if recv == nil {
+		t.Error("serving a directory did not panic")
+	}
+}
+
+func TestRouterCatchAllTrailingSlash(t *testing.T) {
+	var fp string
+
+	router := New()
+	router.GET("/static/*filepath", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		fp = ps.ByName("filepath")
+	})
+	router.ServeFiles("/src/*filepath", &mockFileSystem{})
+
+	for path, want := range map[string]string{
+		"/static":     "",
+		"/static/":    "/",
+		"/static/a/b": "/a/b",
+	} {
+		fp = "unset"
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || fp != want {
+			t.Errorf("%s: want Code=200 filepath=%q, got Code=%d filepath=%q", path, want, w.Code, fp)
+		}
+	}
+
+	// the root directory of the file server is redirected once
+	r, _ := http.NewRequest(http.MethodGet, "/src", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/src/" {
+		t.Errorf("file server root not redirected: Code=%d, Header=%v", w.Code, w.Header())
+	}
+}
+
+func TestRouterFallback(t *testing.T) {
+	var health, fallback bool
+	var fallbackPath string
+
+	router := New()
+	router.GET("/health", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		health = true
+	})
+	router.Fallback(http.MethodGet, "/*path", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		fallback = true
+		fallbackPath = ps.ByName("path")
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/health", nil)
+	router.ServeHTTP(w, r)
+	if !health || fallback {
+		t.Fatal("regular route must take priority over the fallback")
+	}
+
+	// trailing slash redirect is still preferred over the fallback
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, "/health/", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently || fallback {
+		t.Fatalf("expected trailing slash redirect, got: Code=%d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, "/api/products/1", nil)
+	router.ServeHTTP(w, r)
+	if !fallback {
+		t.Fatal("fallback route was not called")
+	}
+	if fallbackPath != "/api/products/1" {
+		t.Errorf("wrong fallback param: want %s, got %s", "/api/products/1", fallbackPath)
+	}
+
+	// HEAD requests are served by the GET fallbacks
+	router.HandleHEAD = true
+	fallback = false
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodHead, "/api/products/2", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !fallback || fallbackPath != "/api/products/2" {
+		t.Errorf("HEAD request not served by the GET fallback: Code=%d, path=%s", w.Code, fallbackPath)
+	}
+
+	fallbackOnly := New()
+	fallbackOnly.HandleHEAD = true
+	fallbackOnly.Fallback(http.MethodGet, "/*path", func(_ http.ResponseWriter, _ *http.Request, _ Params) {})
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodHead, "/", nil)
+	fallbackOnly.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("HEAD request without GET routes not served by the GET fallback: Code=%d", w.Code)
+	}
+
+	// fallbacks are registered per method
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, "/api/products", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for method without fallback, got: Code=%d", w.Code)
+	}
+
+	recv := catchPanic(func() {
+		router.Fallback("", "/*path", nil)
+	})
+	if recv == nil {
+		t.Fatal("registering invalid fallback did not panic")
+	}
+}
+
+func TestRouterRunTLSWithoutCertificates(t *testing.T) {
+	router := New()
+
+	if err := router.RunTLS("127.0.0.1:0", "nope.crt", "nope.key"); err == nil {
+		t.Error("RunTLS with missing certificate files did not fail")
+	}
+
+	if err := router.RunTLSConfig("127.0.0.1:0", nil); err == nil {
+		t.Error("RunTLSConfig without certificates did not fail")
+	}
+
+	// Both use the hardened default configuration
+	if len(router.servers) != 2 {
+		t.Fatalf("want 2 servers, got %d", len(router.servers))
+	}
+	for i, server := range router.servers {
+		config := server.TLSConfig
+		if config == nil {
+			t.Fatalf("server %d: TLSConfig not set", i)
+		}
+		if config.MinVersion != tls.VersionTLS12 {
+			t.Errorf("server %d: want MinVersion TLS 1.2, got %x", i, config.MinVersion)
+		}
+		if want := []tls.CurveID{tls.X25519, tls.CurveP256}; !reflect.DeepEqual(config.CurvePreferences, want) {
+			t.Errorf("server %d: want CurvePreferences %v, got %v", i, want, config.CurvePreferences)
+		}
+		if len(config.CipherSuites) == 0 {
+			t.Errorf("server %d: CipherSuites not set", i)
+		}
+		for _, id := range config.CipherSuites {
+			name := tls.CipherSuiteName(id)
+			if !strings.HasPrefix(name, "TLS_ECDHE_") || !(strings.Contains(name, "_GCM_") || strings.Contains(name, "_CHACHA20_POLY1305")) {
+				t.Errorf("server %d: cipher suite %s is not an ECDHE AEAD suite", i, name)
+			}
+		}
+	}
+
+	// A given configuration is used as is
+	config := &tls.Config{MinVersion: tls.VersionTLS13}
+	if err := router.RunTLSConfig("127.0.0.1:0", config); err == nil {
+		t.Error("RunTLSConfig without certificates did not fail")
+	}
+	if router.servers[2].TLSConfig != config {
+		t.Error("RunTLSConfig did not use the given configuration")
+	}
+}
+
+func TestRouterServerTimeouts(t *testing.T) {
+	router := New()
+	router.WriteTimeout = 0
+
+	server := router.newServer(":8080")
+	if server.Addr != ":8080" || server.Handler != router {
+		t.Fatal("server is not attached to the router")
+	}
+	if server.ReadTimeout != DefaultReadTimeout || server.ReadHeaderTimeout != DefaultReadHeaderTimeout ||
+		server.WriteTimeout != 0 || server.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("wrong server timeouts: %v %v %v %v",
+			server.ReadTimeout, server.ReadHeaderTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+}
+
+func TestRouterShutdown(t *testing.T) {
+	var calls []string
+
+	router := New()
+	router.GET("/", func(_ http.ResponseWriter, _ *http.Request, _ Params) {})
+	router.OnShutdown(func() { calls = append(calls, "first") })
+	router.OnShutdown(func() { calls = append(calls, "second") })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- router.RunListener(listener)
+	}()
+
+	res, err := http.Get("http://" + listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if err := router.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("RunListener returned %v, want %v", err, http.ErrServerClosed)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong shutdown hooks called: want %v, got %v", want, calls)
+	}
+
+	// hooks are limited by the context
+	release := make(chan struct{})
+	defer close(release)
+	router.OnShutdown(func() { <-release })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := router.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRouterRunListener(t *testing.T) {
+	router := New()
+	router.GET("/", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("tcp"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- router.RunListener(listener)
+	}()
+
+	res, err := http.Get("http://" + listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "tcp" {
+		t.Errorf("unexpected body: %q", body)
+	}
+
+	if err := router.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("RunListener returned %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+func TestRouterRunUnix(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fox.sock")
+
+	// leave a stale socket file behind
+	stale, err := net.Listen("unix", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	router := New()
+	router.GET("/", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("unix"))
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- router.RunUnix(file)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", file)
+		},
+	}}
+
+	var res *http.Response
+	for i := 0; i < 100; i++ {
+		if res, err = client.Get("http://fox/"); err == nil {
+			break
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("RunUnix failed: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "unix" {
+		t.Errorf("unexpected body: %q", body)
+	}
+
+	// a socket in use is kept
+	if err := New().RunUnix(file); err == nil {
+		t.Error("RunUnix on a socket in use did not fail")
+	}
+
+	if err := router.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("RunUnix returned %v, want %v", err, http.ErrServerClosed)
+	}
+	if _, err := os.Lstat(file); !os.IsNotExist(err) {
+		t.Errorf("socket file not removed: %v", err)
+	}
+
+	// other files are kept
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := New().RunUnix(file); err == nil {
+		t.Error("RunUnix on a regular file did not fail")
+	}
+	if _, err := os.Lstat(file); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+}
+
+func TestRouterOnStart(t *testing.T) {
+	var calls []string
+	errStart := errors.New("migrations pending")
+
+	router := New()
+	router.OnStart(func() error {
+		calls = append(calls, "first")
+		return nil
+	})
+	router.OnStart(func() error {
+		calls = append(calls, "second")
+		return errStart
+	})
+	router.OnStart(func() error {
+		calls = append(calls, "third")
+		return nil
+	})
+
+	// the port must not be bound
+	if err := router.Run("127.0.0.1:-1"); err != errStart {
+		t.Errorf("Run returned %v, want %v", err, errStart)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong start hooks called: want %v, got %v", want, calls)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := router.RunListener(listener); err != errStart {
+		t.Errorf("RunListener returned %v, want %v", err, errStart)
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	var outer, inner string
+
+	router := New()
+	router.GET("/users/:name", func(w http.ResponseWriter, _ *http.Request, ps Params) {
+		inner = ps.ByName("name")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(inner))
+	})
+	router.GET("/redirect/:name", func(w http.ResponseWriter, _ *http.Request, ps Params) {
+		req, _ := http.NewRequest(http.MethodGet, "/users/"+ps.ByName("name")+"-inner", nil)
+		res := router.Dispatch(req)
+		outer = ps.ByName("name")
+		w.WriteHeader(res.Code)
+		w.Write(res.Body.Bytes())
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/redirect/gopher", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusAccepted || w.Body.String() != "gopher-inner" {
+		t.Errorf("wrong dispatched response: Code=%d, Body=%s", w.Code, w.Body)
+	}
+	if inner != "gopher-inner" || outer != "gopher" {
+		t.Errorf("params corrupted by nested dispatch: outer=%s, inner=%s", outer, inner)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/nope", nil)
+	if res := router.Dispatch(r); res.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown route, got: Code=%d", res.Code)
+	}
+}
+
+func TestRouterDisableParamsPool(t *testing.T) {
+	var kept Params
+
+	router := New()
+	router.DisableParamsPool = true
+	router.GET("/user/:name", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		if kept == nil {
+			kept = ps
+		}
+	})
+
+	for _, name := range []string{"gopher", "fox"} {
+		r, _ := http.NewRequest(http.MethodGet, "/user/"+name, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if want := (Params{{"name", "gopher"}}); !reflect.DeepEqual(kept, want) {
+		t.Errorf("params were reused: want %v, got %v", want, kept)
+	}
+}
+
+func TestRouterMaxPathLength(t *testing.T) {
+	routed := false
+
+	router := New()
+	router.MaxPathLength = 17
+	router.GET("/files/*filepath", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		routed = true
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/files/"+strings.Repeat("a", 10), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !routed {
+		t.Errorf("path within the limit not routed: Code=%d", w.Code)
+	}
+
+	routed = false
+	r, _ = http.NewRequest(http.MethodGet, "/files/"+strings.Repeat("a", 11), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestURITooLong || w.Body.Len() != 0 || routed {
+		t.Errorf("long path handling failed: Code=%d, Body=%q, routed=%v", w.Code, w.Body, routed)
+	}
+
+	// no limit
+	router.MaxPathLength = 0
+	r, _ = http.NewRequest(http.MethodGet, "/files/"+strings.Repeat("a", DefaultMaxPathLength), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !routed {
+		t.Errorf("path without limit not routed: Code=%d", w.Code)
+	}
+}
+
+func TestRouterWrap(t *testing.T) {
+	var calls []string
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	router := New()
+	router.GET("/path", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		calls = append(calls, "handle")
+	})
+	router.Wrap(middleware("first"), middleware("second"))
+	router.Wrap(middleware("third"))
+
+	r, _ := http.NewRequest(http.MethodGet, "/path", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if want := []string{"first", "second", "third", "handle"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong call order: want %v, got %v", want, calls)
+	}
+
+	// unmatched requests are wrapped too
+	calls = nil
+	r, _ = http.NewRequest(http.MethodGet, "/nope", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if want := []string{"first", "second", "third"}; w.Code != http.StatusNotFound || !reflect.DeepEqual(calls, want) {
+		t.Errorf("NotFound not wrapped: Code=%d, calls=%v", w.Code, calls)
+	}
+
+	// Dispatch bypasses the middleware
+	calls = nil
+	r, _ = http.NewRequest(http.MethodGet, "/path", nil)
+	router.Dispatch(r)
+	if want := []string{"handle"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Dispatch must not be wrapped: want %v, got %v", want, calls)
+	}
+}
+
+func TestRouterMergeSlashes(t *testing.T) {
+	var got string
+
+	router := New()
+	router.RedirectFixedPath = false
+	router.GET("/products/:id", func(_ http.ResponseWriter, r *http.Request, ps Params) {
+		got = ps.ByName("id") + " " + r.URL.Path
+	})
+	router.GET("/a/b/", func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		got = r.URL.Path
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/products//1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("duplicate slashes must not match by default: Code=%d", w.Code)
+	}
+
+	router.MergeSlashes = true
+
+	testRoutes := []struct {
+		route string
+		code  int
+		want  string
+	}{
+		{"/products//1", http.StatusOK, "1 /products/1"},
+		{"//products/1", http.StatusOK, "1 /products/1"},
+		{"/a//b//", http.StatusOK, "/a/b/"},
+		{"/a//b", http.StatusMovedPermanently, ""}, // TSR on the merged path
+		{"//", http.StatusNotFound, ""},
+	}
+	for _, tr := range testRoutes {
+		got = ""
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = tr.route // avoid parsing a leading // as host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tr.code || got != tr.want {
+			t.Errorf("route %s: want Code=%d %q, got Code=%d %q", tr.route, tr.code, tr.want, w.Code, got)
+		}
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/a//b", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if location := w.Header().Get("Location"); location != "/a/b/" {
+		t.Errorf("wrong redirect location: want %s, got %s", "/a/b/", location)
+	}
+}
+
+func TestRouterAllowedMethods(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.POST("/path", handlerFunc)
+	router.GET("/path", handlerFunc)
+	router.DELETE("/other", handlerFunc)
+	router.OPTIONS("/path", handlerFunc) // must be ignored
+
+	tests := map[string][]string{
+		"/path":  {"GET", "OPTIONS", "POST"},
+		"/other": {"DELETE", "OPTIONS"},
+		"*":      {"DELETE", "GET", "OPTIONS", "POST"},
+		"/nope":  nil,
+	}
+	for path, want := range tests {
+		if got := router.AllowedMethods(path); !reflect.DeepEqual(got, want) {
+			t.Errorf("AllowedMethods(%q): want %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestAllowedMethodsFromContext(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if allowed := AllowedMethodsFromContext(r.Context()); allowed != nil {
+		t.Errorf("expected no allowed methods, got %v", allowed)
+	}
+}
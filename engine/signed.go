@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignedURLOptions configures the signed URLs of SignedURL and SignURL.
+type SignedURLOptions struct {
+	// Names of the query parameters holding the hex encoded signature and
+	// the expiry time in Unix seconds, "signature" and "expires" by default.
+	SignatureParam string
+	ExpiresParam   string
+
+	// Skew tolerated between the clocks of the signing and the serving
+	// server, a URL is accepted until Skew after its expiry time.
+	Skew time.Duration
+}
+
+func (opts *SignedURLOptions) params() (signature, expires string) {
+	signature, expires = opts.SignatureParam, opts.ExpiresParam
+	if signature == "" {
+		signature = "signature"
+	}
+	if expires == "" {
+		expires = "expires"
+	}
+	return
+}
+
+// SignedURL returns a middleware which only lets requests for URLs signed by
+// SignURL with the same secret and options pass, e.g. for time-limited
+// download links:
+//  signed := engine.SignedURL(secret, engine.SignedURLOptions{Skew: time.Minute})
+//  router.GET("/downloads/:file", signed(Download))
+//
+// The signature covers the path and the query of the URL. Requests without a
+// valid signature, with a tampered path or query or after the expiry time are
+// answered with 403 (Forbidden). Signatures are compared in constant time.
+// The signature and expiry parameters are removed from the query of the
+// request passed to the handle.
+func SignedURL(secret []byte, opts SignedURLOptions) func(HandlerFunc) HandlerFunc {
+	if len(secret) == 0 {
+		panic("secret must not be empty")
+	}
+
+	signatureParam, expiresParam := opts.params()
+
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			query := req.URL.Query()
+
+			signature, err := hex.DecodeString(query.Get(signatureParam))
+			if err != nil || len(signature) == 0 {
+				http.Error(w, "invalid URL signature", http.StatusForbidden)
+				return
+			}
+			query.Del(signatureParam)
+
+			if !hmac.Equal(signature, signURL(secret, req.URL.Path, query)) {
+				http.Error(w, "invalid URL signature", http.StatusForbidden)
+				return
+			}
+
+			expires, err := strconv.ParseInt(query.Get(expiresParam), 10, 64)
+			if err != nil || time.Now().After(time.Unix(expires, 0).Add(opts.Skew)) {
+				http.Error(w, "URL signature expired", http.StatusForbidden)
+				return
+			}
+			query.Del(expiresParam)
+
+			// Strip the parameters on a copy, the request is shared with the
+			// middleware wrapping this one
+			u := *req.URL
+			u.RawQuery = query.Encode()
+			r := new(http.Request)
+			*r = *req
+			r.URL = &u
+
+			handle(w, r, ps)
+		}
+	}
+}
+
+// SignURL returns a copy of u with the expiry time and the signature of
+// SignedURL added to its query:
+//  u := engine.SignURL(secret, &url.URL{Path: "/downloads/report.pdf"}, time.Now().Add(time.Hour), opts)
+//  link := "https://example.com" + u.String()
+func SignURL(secret []byte, u *url.URL, expires time.Time, opts SignedURLOptions) *url.URL {
+	signatureParam, expiresParam := opts.params()
+
+	query := u.Query()
+	query.Del(signatureParam)
+	query.Set(expiresParam, strconv.FormatInt(expires.Unix(), 10))
+	query.Set(signatureParam, hex.EncodeToString(signURL(secret, u.Path, query)))
+
+	signed := *u
+	signed.RawQuery = query.Encode()
+	return &signed
+}
+
+// signURL computes the signature of a path and its query without the
+// signature, the query is canonicalized by sorting it by key.
+func signURL(secret []byte, path string, query url.Values) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(query.Encode()))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURL(t *testing.T) {
+	secret := []byte("secret")
+
+	var query string
+	handlerFunc := func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		query = r.URL.RawQuery
+	}
+
+	opts := SignedURLOptions{Skew: time.Minute}
+	router := New()
+	router.GET("/downloads/:file", SignedURL(secret, opts)(handlerFunc))
+
+	target := &url.URL{Path: "/downloads/report.pdf", RawQuery: "b=2&a=1"}
+	valid := SignURL(secret, target, time.Now().Add(time.Hour), opts).String()
+	expired := SignURL(secret, target, time.Now().Add(-2*time.Minute), opts).String()
+	skewed := SignURL(secret, target, time.Now().Add(-30*time.Second), opts).String()
+	other := SignURL([]byte("other"), target, time.Now().Add(time.Hour), opts).String()
+
+	tests := []struct {
+		url   string
+		code  int
+		query string
+	}{
+		{valid, http.StatusOK, "a=1&b=2"},
+		{skewed, http.StatusOK, "a=1&b=2"},
+		{reorderQuery(valid), http.StatusOK, "a=1&b=2"},
+		{expired, http.StatusForbidden, ""},
+		{other, http.StatusForbidden, ""},
+		{strings.Replace(valid, "a=1", "a=3", 1), http.StatusForbidden, ""},
+		{strings.Replace(valid, "report.pdf", "secret.pdf", 1), http.StatusForbidden, ""},
+		{strings.Replace(valid, "signature=", "signature=zz", 1), http.StatusForbidden, ""},
+		{"/downloads/report.pdf?a=1&b=2", http.StatusForbidden, ""},
+	}
+	for _, test := range tests {
+		query = ""
+		r, _ := http.NewRequest(http.MethodGet, test.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || query != test.query {
+			t.Errorf("%s: want Code=%d query %q, got Code=%d query %q", test.url, test.code, test.query, w.Code, query)
+		}
+	}
+
+	// custom parameter names
+	opts = SignedURLOptions{SignatureParam: "sig", ExpiresParam: "exp"}
+	router.GET("/files/:file", SignedURL(secret, opts)(handlerFunc))
+	signed := SignURL(secret, &url.URL{Path: "/files/a.txt"}, time.Now().Add(time.Hour), opts)
+	if q := signed.Query(); q.Get("sig") == "" || q.Get("exp") == "" {
+		t.Errorf("custom parameters not set: %s", signed)
+	}
+	r, _ := http.NewRequest(http.MethodGet, signed.String(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || query != "" {
+		t.Errorf("custom parameters: want Code=200, got Code=%d query %q", w.Code, query)
+	}
+
+	recv := catchPanic(func() {
+		SignedURL(nil, SignedURLOptions{})
+	})
+	if recv == nil {
+		t.Error("empty secret did not panic")
+	}
+}
+
+// reorderQuery reverses the order of the query parameters of a URL.
+func reorderQuery(s string) string {
+	i := strings.IndexByte(s, '?')
+	params := strings.Split(s[i+1:], "&")
+	for l, r := 0, len(params)-1; l < r; l, r = l+1, r-1 {
+		params[l], params[r] = params[r], params[l]
+	}
+	return s[:i+1] + strings.Join(params, "&")
+}
@@ -0,0 +1,66 @@
+package engine
+
+import "strings"
+
+// PathSyntax is the wildcard syntax of the paths registered on an Engine.
+type PathSyntax uint8
+
+const (
+	// ColonSyntax is the default syntax with :name parameters and *name
+	// catch-all parameters, e.g. /files/:dir/*filepath
+	ColonSyntax PathSyntax = iota
+
+	// BraceSyntax is the OpenAPI like syntax with {name} parameters and
+	// {name...} catch-all parameters, e.g. /files/{dir}/{filepath...}
+	// The braces are translated into the colon syntax when a route is
+	// registered, so both syntaxes follow the same matching rules.
+	// Paths using ':' or '*' are rejected to avoid mixing both syntaxes.
+	BraceSyntax
+)
+
+// translateBracePath translates a path in BraceSyntax into ColonSyntax.
+func translateBracePath(path string) string {
+	if strings.ContainsAny(path, ":*") {
+		panic("path uses ':' or '*' with brace syntax in path '" + path + "'")
+	}
+
+	if strings.IndexByte(path, '{') < 0 {
+		if strings.IndexByte(path, '}') >= 0 {
+			panic("unbalanced braces in path '" + path + "'")
+		}
+		return path
+	}
+
+	buf := make([]byte, 0, len(path))
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			end := strings.IndexByte(path[i:], '}')
+			if end < 0 {
+				panic("unbalanced braces in path '" + path + "'")
+			}
+			name := path[i+1 : i+end]
+			if strings.ContainsAny(name, "{/") {
+				panic("unbalanced braces in path '" + path + "'")
+			}
+
+			if strings.HasSuffix(name, "...") {
+				buf = append(buf, '*')
+				name = name[:len(name)-3]
+			} else {
+				buf = append(buf, ':')
+			}
+
+			// Leave the validation of the name to the tree
+			buf = append(buf, name...)
+			i += end
+
+		case '}':
+			panic("unbalanced braces in path '" + path + "'")
+
+		default:
+			buf = append(buf, path[i])
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTranslateBracePath(t *testing.T) {
+	tests := []struct {
+		path, result string
+	}{
+		{"/", "/"},
+		{"/products", "/products"},
+		{"/products/{id}", "/products/:id"},
+		{"/products/{id}/comments/{comment_id}", "/products/:id/comments/:comment_id"},
+		{"/files/{filepath...}", "/files/*filepath"},
+		{"/{dir}/{filepath...}", "/:dir/*filepath"},
+	}
+	for _, test := range tests {
+		if s := translateBracePath(test.path); s != test.result {
+			t.Errorf("translateBracePath(%q) = %q, want %q", test.path, s, test.result)
+		}
+	}
+
+	for _, path := range []string{"/products/:id", "/files/*filepath", "/{id", "/id}", "/{a/b}", "/{{id}}"} {
+		recv := catchPanic(func() {
+			translateBracePath(path)
+		})
+		if recv == nil {
+			t.Errorf("invalid path '%s' did not panic", path)
+		}
+	}
+}
+
+func TestRouterBraceSyntax(t *testing.T) {
+	var got Params
+
+	router := New()
+	router.PathSyntax = BraceSyntax
+	router.SaveMatchedRoutePath = true
+	router.GET("/products/{id}", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = ps
+	})
+	router.GET("/files/{filepath...}", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = ps
+	})
+
+	testRoutes := []struct {
+		path string
+		ps   Params
+	}{
+		{"/products/1", Params{{"id", "1"}, {MatchedRoutePathParam, "/products/{id}"}}},
+		{"/files/a/b", Params{{"filepath", "/a/b"}, {MatchedRoutePathParam, "/files/{filepath...}"}}},
+	}
+	for _, tr := range testRoutes {
+		got = nil
+		r, _ := http.NewRequest(http.MethodGet, tr.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(got, tr.ps) {
+			t.Errorf("route %s: want params %v, got %v", tr.path, tr.ps, got)
+		}
+	}
+
+	handle := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+	for _, path := range []string{"/users/:id", "/products/{}", "/static/{...}", "/static/{filepath...}/x"} {
+		recv := catchPanic(func() {
+			router.GET(path, handle)
+		})
+		if recv == nil {
+			t.Errorf("registering '%s' with brace syntax did not panic", path)
+		}
+	}
+
+	mfs := &mockFileSystem{}
+	recv := catchPanic(func() {
+		router.ServeFiles("/src/*filepath", mfs)
+	})
+	if recv == nil {
+		t.Error("ServeFiles with colon syntax path did not panic")
+	}
+
+	router.ServeFiles("/src/{filepath...}", mfs)
+	r, _ := http.NewRequest(http.MethodGet, "/src/favicon.ico", nil)
+	router.ServeHTTP(new(mockResponseWriter), r)
+	if !mfs.opened {
+		t.Error("serving file failed")
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout returns a middleware which serves the handles it wraps with a
+// request context canceled after d. If the deadline passed and the handle
+// returned without writing a response, it is answered with 503 (Service
+// Unavailable):
+//  timeout := engine.Timeout(5 * time.Second)
+//  router.GET("/reports/:id", timeout(Report))
+//
+// The cancellation is cooperative, the handle keeps running until it returns
+// and has to pass req.Context() on to database queries and outgoing requests
+// to stop early. Handles which don't check the context should be served with
+// http.TimeoutHandler instead, which answers in time but abandons the
+// goroutine of the handle:
+//  router.Wrap(func(h http.Handler) http.Handler {
+//      return http.TimeoutHandler(h, 5*time.Second, "")
+//  })
+func Timeout(d time.Duration) func(HandlerFunc) HandlerFunc {
+	if d <= 0 {
+		panic("timeout must be positive")
+	}
+
+	return func(handle HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, ps Params) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+
+			tw := &timeoutResponseWriter{ResponseWriter: w}
+			handle(tw, req.WithContext(ctx), ps)
+
+			if !tw.written && ctx.Err() == context.DeadlineExceeded {
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+		}
+	}
+}
+
+// timeoutResponseWriter records whether the handle wrote a response.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *timeoutResponseWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutResponseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter if it supports it.
+func (w *timeoutResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	timeout := Timeout(10 * time.Millisecond)
+
+	router := New()
+	router.GET("/fast", timeout(func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("fast"))
+	}))
+	router.GET("/slow", timeout(func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		<-r.Context().Done()
+	}))
+	router.GET("/late", timeout(func(w http.ResponseWriter, r *http.Request, _ Params) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	router.GET("/flush", timeout(func(w http.ResponseWriter, r *http.Request, _ Params) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/fast", http.StatusOK, "fast"},
+		{"/slow", http.StatusServiceUnavailable, "Service Unavailable\n"},
+		{"/late", http.StatusAccepted, ""},
+		{"/flush", http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s: want Code=%d body %q, got Code=%d body %q", test.path, test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+
+	recv := catchPanic(func() {
+		Timeout(0)
+	})
+	if recv == nil {
+		t.Error("zero timeout did not panic")
+	}
+}
@@ -79,6 +79,9 @@ type node struct {
 	priority  uint32
 	children  []*node
 	handle    HandlerFunc
+
+	// value of the leaf of a Tree, its handle only marks the leaf
+	value interface{}
 }
 
 // Increments priority of the given child and reorders if necessary
@@ -104,17 +107,18 @@ func (n *node) incrementChildPrio(pos int) int {
 	return newPos
 }
 
-// addRoute adds a node with the given handle to the path.
+// addRoute adds a node with the given handle to the path and returns the leaf
+// holding the handle.
 // Not concurrency-safe!
-func (n *node) addRoute(path string, handle HandlerFunc) {
+func (n *node) addRoute(path string, handle HandlerFunc) *node {
 	fullPath := path
 	n.priority++
 
 	// Empty tree
 	if n.path == "" && n.indices == "" {
-		n.insertChild(path, fullPath, handle)
+		leaf := n.insertChild(path, fullPath, handle)
 		n.nType = root
-		return
+		return leaf
 	}
 
 walk:
@@ -133,6 +137,7 @@ walk:
 				indices:   n.indices,
 				children:  n.children,
 				handle:    n.handle,
+				value:     n.value,
 				priority:  n.priority - 1,
 			}
 
@@ -141,6 +146,7 @@ walk:
 			n.indices = string([]byte{n.path[i]})
 			n.path = path[:i]
 			n.handle = nil
+			n.value = nil
 			n.wildChild = false
 		}
 
@@ -201,8 +207,7 @@ walk:
 				n.incrementChildPrio(len(n.indices) - 1)
 				n = child
 			}
-			n.insertChild(path, fullPath, handle)
-			return
+			return n.insertChild(path, fullPath, handle)
 		}
 
 		// Otherwise add handle to current node
@@ -210,11 +215,11 @@ walk:
 			panic("a handle is already registered for path '" + fullPath + "'")
 		}
 		n.handle = handle
-		return
+		return n
 	}
 }
 
-func (n *node) insertChild(path, fullPath string, handle HandlerFunc) {
+func (n *node) insertChild(path, fullPath string, handle HandlerFunc) *node {
 	for {
 		// Find prefix until first wildcard
 		wildcard, i, valid := findWildcard(path)
@@ -271,7 +276,7 @@ func (n *node) insertChild(path, fullPath string, handle HandlerFunc) {
 
 			// Otherwise we're done. Insert the handle in the new leaf
 			n.handle = handle
-			return
+			return n
 		}
 
 		// catchAll
@@ -310,12 +315,13 @@ func (n *node) insertChild(path, fullPath string, handle HandlerFunc) {
 		}
 		n.children = []*node{child}
 
-		return
+		return child
 	}
 
 	// If no wildcard was found, simply insert the path and handle
 	n.path = path
 	n.handle = handle
+	return n
 }
 
 // Returns the handle registered with the given path (key). The values of
@@ -324,6 +330,16 @@ func (n *node) insertChild(path, fullPath string, handle HandlerFunc) {
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
 func (n *node) getValue(path string, params func() *Params) (handle HandlerFunc, ps *Params, tsr bool) {
+	var leaf *node
+	if leaf, ps, tsr = n.getLeaf(path, params); leaf != nil {
+		handle = leaf.handle
+	}
+	return
+}
+
+// getLeaf returns the leaf node holding the handle registered with the given
+// path like getValue, or nil.
+func (n *node) getLeaf(path string, params func() *Params) (leaf *node, ps *Params, tsr bool) {
 walk: // Outer loop for walking the tree
 	for {
 		prefix := n.path
@@ -387,13 +403,21 @@ walk: // Outer loop for walking the tree
 						return
 					}
 
-					if handle = n.handle; handle != nil {
+					if n.handle != nil {
+						leaf = n
 						return
 					} else if len(n.children) == 1 {
+						n = n.children[0]
+
+						// A catch-all also matches the empty remainder
+						if n.path == "" && n.indices == "/" {
+							leaf, ps = n.children[0].children[0].getEmptyCatchAll(params, ps)
+							return
+						}
+
 						// No handle found. Check if a handle for this path + a
 						// trailing slash exists for TSR recommendation
-						n = n.children[0]
-						tsr = (n.path == "/" && n.handle != nil) || (n.path == "" && n.indices == "/")
+						tsr = (n.path == "/" && n.handle != nil)
 					}
 
 					return
@@ -413,7 +437,7 @@ walk: // Outer loop for walking the tree
 						}
 					}
 
-					handle = n.handle
+					leaf = n
 					return
 
 				default:
@@ -423,7 +447,8 @@ walk: // Outer loop for walking the tree
 		} else if path == prefix {
 			// We should have reached the node containing the handle.
 			// Check if this node has a handle registered.
-			if handle = n.handle; handle != nil {
+			if n.handle != nil {
+				leaf = n
 				return
 			}
 
@@ -440,8 +465,14 @@ walk: // Outer loop for walking the tree
 			for i, c := range []byte(n.indices) {
 				if c == '/' {
 					n = n.children[i]
-					tsr = (len(n.path) == 1 && n.handle != nil) ||
-						(n.nType == catchAll && n.children[0].handle != nil)
+
+					// A catch-all also matches the empty remainder
+					if n.nType == catchAll {
+						leaf, ps = n.children[0].getEmptyCatchAll(params, ps)
+						return
+					}
+
+					tsr = (len(n.path) == 1 && n.handle != nil)
 					return
 				}
 			}
@@ -457,6 +488,33 @@ walk: // Outer loop for walking the tree
 	}
 }
 
+// getEmptyCatchAll returns the catch-all node n as the leaf for an empty
+// remainder of the path, the value of the catch-all parameter is empty.
+func (n *node) getEmptyCatchAll(params func() *Params, ps *Params) (*node, *Params) {
+	if n.handle == nil {
+		return nil, ps
+	}
+	if params != nil {
+		if ps == nil {
+			ps = params()
+		}
+		// Expand slice within preallocated capacity
+		i := len(*ps)
+		*ps = (*ps)[:i+1]
+		(*ps)[i] = Param{
+			Key:   n.path[2:],
+			Value: "",
+		}
+	}
+	return n, ps
+}
+
+// match reports whether a handle is registered for the given path.
+func (n *node) match(path string) bool {
+	handle, _, _ := n.getValue(path, nil)
+	return handle != nil
+}
+
 // Makes a case-insensitive lookup of the given path and tries to find a handler.
 // It can optionally also fix trailing slashes.
 // It returns the case-corrected path and a bool indicating whether the lookup
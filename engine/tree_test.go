@@ -171,13 +171,16 @@ func TestTreeWildcard(t *testing.T) {
 		{"/cmd/test/", false, "/cmd/:tool/", Params{Param{"tool", "test"}}},
 		{"/cmd/test", true, "", Params{Param{"tool", "test"}}},
 		{"/cmd/test/3", false, "/cmd/:tool/:sub", Params{Param{"tool", "test"}, Param{"sub", "3"}}},
+		{"/src", false, "/src/*filepath", Params{Param{"filepath", ""}}},
 		{"/src/", false, "/src/*filepath", Params{Param{"filepath", "/"}}},
 		{"/src/some/file.png", false, "/src/*filepath", Params{Param{"filepath", "/some/file.png"}}},
+		{"/src/a/b", false, "/src/*filepath", Params{Param{"filepath", "/a/b"}}},
 		{"/search/", false, "/search/", nil},
 		{"/search/someth!ng+in+ünìcodé", false, "/search/:query", Params{Param{"query", "someth!ng+in+ünìcodé"}}},
 		{"/search/someth!ng+in+ünìcodé/", true, "", Params{Param{"query", "someth!ng+in+ünìcodé"}}},
 		{"/user_gopher", false, "/user_:name", Params{Param{"name", "gopher"}}},
 		{"/user_gopher/about", false, "/user_:name/about", Params{Param{"name", "gopher"}}},
+		{"/files/js", false, "/files/:dir/*filepath", Params{Param{"dir", "js"}, Param{"filepath", ""}}},
 		{"/files/js/inc/framework.js", false, "/files/:dir/*filepath", Params{Param{"dir", "js"}, Param{"filepath", "/inc/framework.js"}}},
 		{"/info/gordon/public", false, "/info/:user/public", Params{Param{"user", "gordon"}}},
 		{"/info/gordon/project/go", false, "/info/:user/project/:project", Params{Param{"user", "gordon"}, Param{"project", "go"}}},
@@ -411,7 +414,6 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/b",
 		"/search/gopher/",
 		"/cmd/vet",
-		"/src",
 		"/x/",
 		"/y",
 		"/0/go/",
@@ -421,7 +423,6 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/admin/config/",
 		"/admin/config/permissions/",
 		"/doc/",
-		"/vendor/x",
 	}
 	for _, route := range tsrRoutes {
 		handler, _, tsr := tree.getValue(route, nil)
@@ -0,0 +1,131 @@
+// Package foxtest provides utilities for testing handlers served by an engine.
+//
+// A typical test looks like:
+//
+//  func TestHello(t *testing.T) {
+//      router := engine.New()
+//      router.GET("/hello/:name", Hello)
+//
+//      w := foxtest.PerformRequest(router, http.MethodGet, "/hello/gopher", nil)
+//      foxtest.AssertStatus(t, w, http.StatusOK)
+//      foxtest.AssertHeader(t, w, "Content-Type", "application/json")
+//      foxtest.AssertJSON(t, w, map[string]string{"hello": "gopher"})
+//  }
+package foxtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/miclle/fox/engine"
+)
+
+// Header is a single request header used by PerformRequest.
+type Header struct {
+	Key   string
+	Value string
+}
+
+// PerformRequest builds a request with the given method, path and body, serves
+// it with handler and returns the recorded response.
+func PerformRequest(handler http.Handler, method, path string, body io.Reader, headers ...Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	for _, h := range headers {
+		req.Header.Add(h.Key, h.Value)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// PerformJSONRequest is like PerformRequest, but encodes v as the JSON request
+// body and sets the Content-Type header accordingly.
+func PerformJSONRequest(handler http.Handler, method, path string, v interface{}, headers ...Header) *httptest.ResponseRecorder {
+	req := NewJSONRequest(method, path, v)
+	for _, h := range headers {
+		req.Header.Add(h.Key, h.Value)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// NewJSONRequest returns a new incoming server request with v encoded as the
+// JSON body. It panics if v can not be encoded, like httptest.NewRequest does
+// for invalid input.
+func NewJSONRequest(method, path string, v interface{}) *http.Request {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic("foxtest: invalid JSON body: " + err.Error())
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// WithParams returns a shallow copy of req carrying the given URL parameters
+// in its context, the same way engine.Router.Handler does for matched routes.
+// This allows testing http.Handler based handlers without a router.
+func WithParams(req *http.Request, ps engine.Params) *http.Request {
+	ctx := context.WithValue(req.Context(), engine.ParamsKey, ps)
+	return req.WithContext(ctx)
+}
+
+// WithValue returns a shallow copy of req whose context carries val for key.
+func WithValue(req *http.Request, key, val interface{}) *http.Request {
+	ctx := context.WithValue(req.Context(), key, val)
+	return req.WithContext(ctx)
+}
+
+// AssertStatus reports an error if the recorded status code isn't code.
+func AssertStatus(t testing.TB, w *httptest.ResponseRecorder, code int) {
+	t.Helper()
+
+	if w.Code != code {
+		t.Errorf("unexpected status code: want %d, got %d", code, w.Code)
+	}
+}
+
+// AssertHeader reports an error if the recorded header key isn't value.
+func AssertHeader(t testing.TB, w *httptest.ResponseRecorder, key, value string) {
+	t.Helper()
+
+	if got := w.Header().Get(key); got != value {
+		t.Errorf("unexpected %s header value: want %q, got %q", key, value, got)
+	}
+}
+
+// AssertJSON reports an error if the recorded body is not a JSON document
+// equal to want. Both sides are compared in their decoded form, so key order
+// and whitespace don't matter.
+func AssertJSON(t testing.TB, w *httptest.ResponseRecorder, want interface{}) {
+	t.Helper()
+
+	var got interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Errorf("invalid JSON body %q: %v", w.Body.String(), err)
+		return
+	}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Errorf("invalid JSON value %v: %v", want, err)
+		return
+	}
+
+	var expected interface{}
+	json.Unmarshal(data, &expected)
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected JSON body: want %s, got %s", data, w.Body.Bytes())
+	}
+}
@@ -0,0 +1,73 @@
+package foxtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/miclle/fox/engine"
+)
+
+type product struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestPerformRequest(t *testing.T) {
+	router := engine.New()
+	router.GET("/products/:id", func(w http.ResponseWriter, r *http.Request, ps engine.Params) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Trace", r.Header.Get("X-Trace"))
+		json.NewEncoder(w).Encode(product{ID: ps.ByName("id"), Name: "fox"})
+	})
+	router.POST("/products", func(w http.ResponseWriter, r *http.Request, _ engine.Params) {
+		var p product
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+	})
+
+	w := PerformRequest(router, http.MethodGet, "/products/1", nil, Header{"X-Trace", "abc"})
+	AssertStatus(t, w, http.StatusOK)
+	AssertHeader(t, w, "Content-Type", "application/json")
+	AssertHeader(t, w, "X-Trace", "abc")
+	AssertJSON(t, w, product{ID: "1", Name: "fox"})
+
+	w = PerformJSONRequest(router, http.MethodPost, "/products", product{ID: "2", Name: "new"})
+	AssertStatus(t, w, http.StatusCreated)
+	AssertJSON(t, w, map[string]string{"name": "new", "id": "2"})
+
+	w = PerformRequest(router, http.MethodGet, "/nope", nil)
+	AssertStatus(t, w, http.StatusNotFound)
+}
+
+type contextKey struct{}
+
+func TestRequestContext(t *testing.T) {
+	var gotID string
+	var gotUser interface{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = engine.ParamsFromContext(r.Context()).ByName("id")
+		gotUser = r.Context().Value(contextKey{})
+	})
+
+	req := NewJSONRequest(http.MethodPut, "/products/1", product{Name: "fox"})
+	req = WithParams(req, engine.Params{engine.Param{Key: "id", Value: "1"}})
+	req = WithValue(req, contextKey{}, "gopher")
+	handler.ServeHTTP(nil, req)
+
+	if gotID != "1" {
+		t.Errorf("wrong param value: want %s, got %s", "1", gotID)
+	}
+	if gotUser != "gopher" {
+		t.Errorf("wrong context value: want %s, got %v", "gopher", gotUser)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"net/http"
 )
 
-// ResponseWriter ...
+// ResponseWriter wraps the http.ResponseWriter of a request and tracks the
+// status code and body size written through it.
+// Status, Size and Written are read-only snapshots of the response so far,
+// middleware can read them after the handlers ran to log or record metrics.
 type ResponseWriter interface {
 	http.ResponseWriter
 	http.Hijacker
@@ -13,16 +16,20 @@ type ResponseWriter interface {
 	http.CloseNotifier
 
 	// Returns the HTTP response status code of the current request.
+	// Before the header is written it returns the status that will be sent,
+	// http.StatusOK unless changed with WriteHeader.
 	Status() int
 
-	// Returns the number of bytes already written into the response http body.
+	// Returns the number of bytes already written into the response http body,
+	// or -1 if nothing was written yet, not even the header.
 	// See Written()
 	Size() int
 
 	// Writes the string into the response body.
 	WriteString(string) (int, error)
 
-	// Returns true if the response body was already written.
+	// Returns true if the response header was already written, either
+	// explicitly or by writing to the body. It stays true after WriteHeaderNow.
 	Written() bool
 
 	// Forces to write the http header (status code + headers).
@@ -39,6 +46,8 @@ var RequestIDKey = "x-request-id"
 type Context interface {
 
 	// Copy returns a copy of the current context that can be safely used outside the request's scope.
+	// It must be used when the context is passed to a goroutine which outlives the handler.
+	// Only reading request data and stored values is safe on the copy, its Writer must not be used.
 	Copy() Context
 
 	// Request with http.Request